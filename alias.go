@@ -0,0 +1,146 @@
+package treemux
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Alias is an additional pattern of a route, see WithAlias.
+type Alias struct {
+	// Pattern is the pattern of the alias, relative to the group as
+	// the path of the route.
+	Pattern string
+
+	// Params maps the parameter names of Pattern to the parameter names
+	// of the route, parameters not in Params keep their names.
+	Params map[string]string
+
+	// Redirect tells to redirect requests of the alias to the canonical
+	// URL, according to Router.RedirectBehavior. If it is false, or the
+	// redirect behavior of the method is UseHandler, requests of the
+	// alias are served by the route's handler directly.
+	Redirect bool
+}
+
+// WithAlias returns a RouteOption which registers aliases of the route,
+// e.g. to keep the old paths of a renamed route working.
+//
+// An alias shares the handler, middlewares and options of the route,
+// the lookup reports the route's pattern as RoutePath and the
+// parameters with the names of the route, thus metrics and docs see a
+// single route. The alias patterns are listed in RouteInfo.Aliases.
+//
+// It panics if a parameter of the route is not provided by an alias,
+// or an alias redirects to a route with a regular expression segment.
+func WithAlias[T HandlerConstraint](aliases ...Alias) RouteOption[T] {
+	return func(opts *routeOptions[T]) {
+		opts.aliases = append(opts.aliases, aliases...)
+	}
+}
+
+// routeAlias is the alias of a route on a node.
+type routeAlias struct {
+	// path is the pattern of the canonical route.
+	path string
+
+	// keys is the parameter names of the node mapped to the names
+	// of the canonical route.
+	keys     []string
+	redirect bool
+}
+
+func aliasPatterns(prefix string, aliases []Alias) []string {
+	if len(aliases) == 0 {
+		return nil
+	}
+	patterns := make([]string, 0, len(aliases))
+	for _, alias := range aliases {
+		patterns = append(patterns, prefix+alias.Pattern)
+	}
+	return patterns
+}
+
+func newRouteAlias(alias *Alias, canonical string, paramNames []string) *routeAlias {
+	ra := &routeAlias{path: canonical, redirect: alias.Redirect}
+	provided := make(map[string]bool, len(paramNames))
+	for _, name := range paramNames {
+		if mapped, ok := alias.Params[name]; ok {
+			name = mapped
+		}
+		ra.keys = append(ra.keys, name)
+		provided[name] = true
+	}
+	for _, name := range patternParamNames(canonical) {
+		if !provided[name] {
+			panic(fmt.Sprintf("treemux: alias %q does not provide parameter %q of route %q",
+				alias.Pattern, name, canonical))
+		}
+	}
+	if alias.Redirect {
+		if _, err := buildPath(canonical, func(string) (string, bool) { return "", true }); err != nil {
+			panic(fmt.Sprintf("treemux: alias %q can not redirect to route %q: %v",
+				alias.Pattern, canonical, err))
+		}
+	}
+	return ra
+}
+
+// errRegexpSegment is returned by buildPath for patterns which have a
+// regular expression segment.
+var errRegexpSegment = errors.New("regexp segment")
+
+// buildPath builds a path from the route pattern, the segments in the
+// form ":name" and "*name" are replaced by the escaped values of the
+// parameters given by param, escaped segments, e.g. "\:name", are
+// unescaped. It fails if a parameter is missing, or the pattern has a
+// regular expression segment.
+func buildPath(pattern string, param func(name string) (string, bool)) (string, error) {
+	segments := strings.Split(pattern, "/")
+	for i, seg := range segments {
+		if seg == "" {
+			continue
+		}
+		switch seg[0] {
+		case ':', '*':
+			value, ok := param(seg[1:])
+			if !ok {
+				return "", fmt.Errorf("missing param %q", seg[1:])
+			}
+			segments[i] = escapePathParam(seg[0] == '*', value)
+		case '~':
+			return "", errRegexpSegment
+		case '\\':
+			if len(seg) > 1 && strings.IndexByte(`*:~\`, seg[1]) >= 0 {
+				segments[i] = seg[1:]
+			}
+		}
+	}
+	return strings.Join(segments, "/"), nil
+}
+
+// escapeTargetPath is like expandTargetPath, but escapes the parameter
+// values, thus a value can not change the structure of the path, e.g.
+// the value "//evil.com" of an escaped request path does not make the
+// path an URL of another host. Missing parameters are empty, path must
+// not have regular expression segments, see buildPath.
+func escapeTargetPath(path string, params Params) string {
+	p, _ := buildPath(path, func(name string) (string, bool) {
+		return params.Get(name), true
+	})
+	return p
+}
+
+// escapePathParam escapes the value of a path parameter, the segments
+// of a catch-all value are escaped separately.
+func escapePathParam(catchAll bool, value string) string {
+	if !catchAll {
+		return url.PathEscape(value)
+	}
+	parts := strings.Split(strings.TrimLeft(value, "/"), "/")
+	for i, part := range parts {
+		parts[i] = url.PathEscape(part)
+	}
+	return strings.Join(parts, "/")
+}
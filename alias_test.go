@@ -0,0 +1,144 @@
+package treemux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAlias(t *testing.T) {
+	router := New[HandlerFunc]()
+	router.UseContextData = true
+	var gotRoute string
+	var gotParams Params
+	handler := func(w http.ResponseWriter, r *http.Request, params Params) {
+		gotRoute = GetContextData(r).Route()
+		gotParams = params
+	}
+	api := router.NewGroup("/api")
+	api.GET("/users/:id/posts/:post", handler, WithAlias[HandlerFunc](
+		Alias{Pattern: "/people/:uid/articles/:post", Params: map[string]string{"uid": "id"}},
+		Alias{Pattern: "/members/:id/posts/:post", Redirect: true},
+	))
+
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		gotRoute, gotParams = "", Params{}
+		w := httptest.NewRecorder()
+		r, _ := newRequest(method, path, nil)
+		router.ServeHTTP(w, r)
+		return w
+	}
+
+	w := serve("GET", "/api/people/7/articles/9")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if gotRoute != "/api/users/:id/posts/:post" {
+		t.Errorf("expected the canonical route, got %q", gotRoute)
+	}
+	if gotParams.Get("id") != "7" || gotParams.Get("post") != "9" || gotParams.Get("uid") != "" {
+		t.Errorf("expected the canonical params, got %v", gotParams)
+	}
+
+	lr, _ := router.LookupByPath("GET", "/api/people/7/articles/9", "/api/people/7/articles/9")
+	if lr.RoutePath != "/api/users/:id/posts/:post" {
+		t.Errorf("expected the canonical RoutePath, got %q", lr.RoutePath)
+	}
+
+	w = serve("GET", "/api/members/7/posts/9?x=1")
+	if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != "/api/users/7/posts/9?x=1" {
+		t.Errorf("expected a redirect to the canonical URL, got %d %q", w.Code, w.Header().Get("Location"))
+	}
+	router.RedirectMethodBehavior["GET"] = UseHandler
+	if w := serve("GET", "/api/members/7/posts/9"); w.Code != http.StatusOK || gotParams.Get("id") != "7" {
+		t.Errorf("expected the handler to serve with UseHandler, got %d %v", w.Code, gotParams)
+	}
+
+	routes := router.Routes()
+	if len(routes) != 1 {
+		t.Fatalf("expected aliases not to be separate routes, got %d routes", len(routes))
+	}
+	want := []string{"/api/people/:uid/articles/:post", "/api/members/:id/posts/:post"}
+	if aliases := routes[0].Aliases; len(aliases) != 2 || aliases[0] != want[0] || aliases[1] != want[1] {
+		t.Errorf("expected aliases %v, got %v", want, aliases)
+	}
+}
+
+func TestAliasMissingParam(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic for the missing parameter")
+		}
+	}()
+	router := New[HandlerFunc]()
+	router.GET("/users/:id", func(w http.ResponseWriter, r *http.Request, params Params) {},
+		WithAlias[HandlerFunc](Alias{Pattern: "/people/:uid"}))
+}
+
+func TestAliasRedirectEscapesParams(t *testing.T) {
+	router := New[HandlerFunc]()
+	handler := func(w http.ResponseWriter, r *http.Request, params Params) {}
+	router.GET("/:id", handler, WithAlias[HandlerFunc](Alias{Pattern: "/old/:id", Redirect: true}))
+	router.GET("/files/*path", handler, WithAlias[HandlerFunc](Alias{Pattern: "/old-files/*path", Redirect: true}))
+	router.GET(`/\:v1/:id`, handler, WithAlias[HandlerFunc](Alias{Pattern: "/v1/:id", Redirect: true}))
+
+	for path, want := range map[string]string{
+		"/old/%2F%2Fevil.com":       "/%2F%2Fevil.com",
+		"/old/a%20b":                "/a%20b",
+		"/old-files/a/b%3Fc":        "/files/a/b%3Fc",
+		"/old-files/%2F%2Fevil.com": "/files/evil.com",
+		"/v1/a%2Fb":                 "/:v1/a%2Fb",
+	} {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", path, nil)
+		r.RequestURI = path
+		router.ServeHTTP(w, r)
+		if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != want {
+			t.Errorf("%s: expected a redirect to %q, got %d %q", path, want, w.Code, w.Header().Get("Location"))
+		}
+	}
+}
+
+func TestAliasRedirectToRegexp(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic for a redirect to a regexp route")
+		}
+	}()
+	router := New[HandlerFunc]()
+	router.GET(`/users/~^(?P<id>\d+)$`, func(w http.ResponseWriter, r *http.Request, params Params) {},
+		WithAlias[HandlerFunc](Alias{Pattern: "/people/:id", Redirect: true}))
+}
+
+func TestAliasSharedNode(t *testing.T) {
+	router := New[HandlerFunc]()
+	var got string
+	router.GET("/new/:id", func(w http.ResponseWriter, r *http.Request, params Params) {
+		got = "new " + params.Get("id")
+	}, WithAlias[HandlerFunc](Alias{Pattern: "/old/:uid", Params: map[string]string{"uid": "id"}, Redirect: true}))
+	router.POST("/old/:uid", func(w http.ResponseWriter, r *http.Request, params Params) {
+		got = "old " + params.Get("uid")
+	})
+
+	w := httptest.NewRecorder()
+	r, _ := newRequest("POST", "/old/7", nil)
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || got != "old 7" {
+		t.Errorf("expected the POST route to serve, got %d %q", w.Code, got)
+	}
+	lr, _ := router.LookupByPath("POST", "/old/7", "/old/7")
+	if lr.RoutePath != "/old/:uid" {
+		t.Errorf("expected RoutePath /old/:uid, got %q", lr.RoutePath)
+	}
+
+	w = httptest.NewRecorder()
+	r, _ = newRequest("GET", "/old/7", nil)
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != "/new/7" {
+		t.Errorf("expected the GET alias to redirect, got %d %q", w.Code, w.Header().Get("Location"))
+	}
+	lr, _ = router.LookupByPath("GET", "/old/7", "/old/7")
+	if lr.RoutePath != "/new/:id" {
+		t.Errorf("expected RoutePath /new/:id, got %q", lr.RoutePath)
+	}
+}
@@ -1,6 +1,7 @@
 package treemux
 
 import (
+	"context"
 	"net/http"
 	"reflect"
 )
@@ -45,28 +46,92 @@ func (t *Router[T]) ServeLookupResult(
 	r *http.Request,
 	lr LookupResult[T],
 ) {
+	if lr.router != nil && lr.router != t {
+		lr.router.ServeLookupResult(w, r, lr)
+		return
+	}
 	if lr.RedirectPath != "" {
-		redirect(w, r, lr.RedirectPath, lr.StatusCode)
+		if lr.RedirectEscaped {
+			redirectEscaped(w, r, lr.RedirectPath, lr.StatusCode)
+		} else {
+			redirect(w, r, lr.RedirectPath, lr.StatusCode)
+		}
 		return
 	}
 
+	mountPath := GetRouteData(r).MountPath()
 	r = t.setDefaultRequestContext(r)
-	if t.UseContextData {
-		r = AddContextData(r, NewContextData(lr.RoutePath, lr.Params))
+	if t.UseContextData || (lr.route != nil && lr.route.passParams) {
+		rd := &routeData{
+			contextData:  contextData{route: lr.RoutePath, params: lr.Params},
+			version:      lr.Version,
+			variant:      lr.Variant,
+			mediaType:    lr.MediaType,
+			mountPath:    mountPath,
+			locale:       lr.Locale,
+			implicitHead: lr.ImplicitHead,
+		}
+		if t.UseContextData {
+			r = AddContextData(r, rd)
+		} else {
+			r = r.WithContext(context.WithValue(r.Context(), requestDataKey{}, rd))
+		}
 	}
 
 	if t.Bridge == nil {
 		panic("treemux: Bridge is not configured")
 	}
+	for _, h := range lr.Vary {
+		w.Header().Add("Vary", h)
+	}
+	if lr.node != nil && lr.node.hasCORS && t.serveCORS(w, r, lr) {
+		return
+	}
 	if t.Bridge.IsHandlerValid(lr.Handler) {
-		t.Bridge.ToHTTPHandlerFunc(lr.Handler, lr.Params)(w, r)
+		if m := lr.route.Maintenance(); m != nil {
+			t.serveMaintenance(w, r, m)
+			return
+		}
+		if lr.route != nil && (lr.route.Deprecation != nil || lr.route.VersionDeprecated) {
+			if t.serveDeprecation(w, lr) {
+				return
+			}
+		}
+		if lr.route != nil && lr.route.MaxBodySize > 0 && !limitRequestBody(w, r, lr.route.MaxBodySize) {
+			return
+		}
+		handler := t.Bridge.ToHTTPHandlerFunc(lr.Handler, lr.Params)
+		if lr.ImplicitHead {
+			getHandler := handler
+			handler = func(w http.ResponseWriter, r *http.Request) {
+				hw := &headResponseWriter{ResponseWriter: w}
+				getHandler(hw, r)
+				hw.finish()
+			}
+		}
+		if lr.route != nil && lr.route.Timeout > 0 {
+			t.serveWithTimeout(w, r, lr.route.Timeout, handler)
+			return
+		}
+		handler(w, r)
 	} else if lr.StatusCode == http.StatusMethodNotAllowed && len(lr.AllowedMethods) > 0 {
 		t.MethodNotAllowedHandler(w, r, lr.AllowedMethods)
+	} else if lr.StatusCode == http.StatusNotAcceptable {
+		t.NotAcceptableHandler(w, r)
+	} else if lr.StatusCode == http.StatusUnsupportedMediaType {
+		t.UnsupportedMediaTypeHandler(w, r)
 	} else {
 		t.NotFoundHandler(w, r)
 	}
 }
 
+// fromHTTPHandler converts an [http.Handler] to T, using
+// Bridge.ConvertMiddleware with a middleware which ignores the next handler.
+func (t *Router[T]) fromHTTPHandler(h http.Handler) T {
+	var zero T
+	return t.Bridge.ConvertMiddleware(func(http.Handler) http.Handler { return h })(zero)
+}
+
 // UseHandler is like Use but accepts [http.Handler] middleware.
 // It calls the middleware wrapper to convert the given middleware
 // to a MiddlewareFunc.
@@ -76,8 +141,30 @@ func (g *Group[T]) UseHandler(middlewares ...func(http.Handler) http.Handler) {
 	}
 	for _, mw := range middlewares {
 		mw := g.mux.Bridge.ConvertMiddleware(mw)
-		g.stack = append(g.stack, mw)
+		g.stack = append(g.stack, Middleware[T]{Func: mw})
+	}
+}
+
+// WithHandler is like With but accepts [http.Handler] middleware.
+// It calls the middleware wrapper to convert the given middleware
+// to a MiddlewareFunc.
+func (g *Group[T]) WithHandler(middlewares ...func(http.Handler) http.Handler) *Group[T] {
+	if g.mux.Bridge == nil {
+		panic("treemux: Bridge is not configured")
+	}
+	converted := make([]Middleware[T], 0, len(middlewares))
+	for _, mw := range middlewares {
+		converted = append(converted, Middleware[T]{Func: g.mux.Bridge.ConvertMiddleware(mw)})
+	}
+	return g.derive(converted)
+}
+
+// UseNamedHandler is like UseNamed but accepts [http.Handler] middleware.
+func (g *Group[T]) UseNamedHandler(name string, middleware func(http.Handler) http.Handler) {
+	if g.mux.Bridge == nil {
+		panic("treemux: Bridge is not configured")
 	}
+	g.UseNamed(name, g.mux.Bridge.ConvertMiddleware(middleware))
 }
 
 // HandlerFunc is a default handler type.
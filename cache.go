@@ -0,0 +1,500 @@
+package treemux
+
+import (
+	"container/list"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CachePolicy configures the response cache of a route, see WithCache.
+//
+// Responses are cached per route pattern, i.e. LookupResult.RoutePath,
+// plus the values of the selected path parameters, query keys and
+// request headers. Routes of a pattern which are variants, see
+// WithMatchers, Group.Version and WithSplit, are cached separately, so
+// are the media types selected by content negotiation. Only responses of GET requests are stored, HEAD
+// requests are served from the cached GET responses.
+type CachePolicy struct {
+	// TTL is how long a response is cached, it must be positive.
+	TTL time.Duration
+
+	// Params is the path parameters which are part of the cache key.
+	// Nil means all the parameters of the route, an empty non-nil slice
+	// means none.
+	Params []string
+
+	// QueryKeys is the query keys which are part of the cache key,
+	// other query keys are ignored.
+	QueryKeys []string
+
+	// VaryHeaders is the request headers which are part of the cache key.
+	// A response which has a `Vary` header naming other headers is not
+	// cached.
+	VaryHeaders []string
+
+	// StatusCodes is the status codes of cacheable responses.
+	// Nil means only 200 OK.
+	StatusCodes []int
+
+	// MaxBodySize limits the size of cacheable response bodies.
+	// Zero means 1 MiB.
+	MaxBodySize int
+
+	// Store stores the cached responses. If nil, a LRU store in memory
+	// shared by the router is used.
+	Store CacheStore
+}
+
+// equal tells whether the policies are the same, stores are compared
+// by identity.
+func (p *CachePolicy) equal(q *CachePolicy) bool {
+	return p == q || p.TTL == q.TTL &&
+		equalSlices(p.Params, q.Params) &&
+		equalSlices(p.QueryKeys, q.QueryKeys) &&
+		equalSlices(p.VaryHeaders, q.VaryHeaders) &&
+		equalSlices(p.StatusCodes, q.StatusCodes) &&
+		p.MaxBodySize == q.MaxBodySize &&
+		p.Store == q.Store
+}
+
+// equalSlices tells whether the slices have the same elements,
+// a nil slice differs from an empty one.
+func equalSlices[E comparable](a, b []E) bool {
+	if len(a) != len(b) || (a == nil) != (b == nil) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// CachedResponse is a response stored in a CacheStore.
+type CachedResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+
+	// Created is the time when the response is stored.
+	Created time.Time
+
+	// Expires is the time when the response expires.
+	Expires time.Time
+}
+
+// CacheStore stores cached responses by key. Keys of a route pattern
+// share the prefix of the pattern, thus they can be invalidated by
+// DeletePrefix. Implementations must be safe for concurrent use.
+type CacheStore interface {
+	// Get returns the response of key, expired responses must not be
+	// returned.
+	Get(key string) (*CachedResponse, bool)
+
+	// Set stores the response of key.
+	Set(key string, resp *CachedResponse)
+
+	// DeletePrefix deletes the responses whose key has the prefix.
+	DeletePrefix(prefix string)
+}
+
+// WithCache returns a RouteOption which caches the responses of the
+// route. Routes of the same pattern share the cache, they must have the
+// same policy. Concurrent requests of the same key which miss the cache are
+// coalesced, only one of them is served by the handler.
+//
+// Cached responses are served with the `Age` header. Responses with
+// `Cache-Control: no-store` or `private`, or with a `Set-Cookie` header,
+// are not cached. See Router.InvalidateCache to invalidate responses.
+func WithCache[T HandlerConstraint](policy *CachePolicy) RouteOption[T] {
+	if policy == nil {
+		policy = &CachePolicy{}
+	}
+	return func(opts *routeOptions[T]) {
+		opts.cache = policy
+	}
+}
+
+// InvalidateCache deletes the cached responses of the route pattern
+// routePath, i.e. LookupResult.RoutePath.
+//
+// If params is not empty, only the responses of the parameter values
+// are deleted. Parameters are matched in the order of
+// CachePolicy.Params, or the route pattern if it is nil, a parameter
+// missing from params stops the matching, e.g. if the key parameters
+// are "org" and "repo", {"org": "a"} deletes all the responses of the
+// organization "a", while {"repo": "b"} deletes all the responses of
+// the route.
+func (t *Router[T]) InvalidateCache(routePath string, params map[string]string) {
+	t.mutex.RLock()
+	rc := t.caches[routePath]
+	t.mutex.RUnlock()
+	if rc == nil {
+		return
+	}
+	var b strings.Builder
+	b.WriteString(rc.prefix)
+	for _, name := range rc.params {
+		value, ok := params[name]
+		if !ok {
+			break
+		}
+		writeCacheKeyPart(&b, name, value)
+	}
+	rc.store.DeletePrefix(b.String())
+}
+
+// routeCache is the response cache of a route pattern.
+type routeCache struct {
+	policy *CachePolicy
+	store  CacheStore
+	prefix string
+	params []string
+
+	mu    sync.Mutex
+	calls map[string]*cacheCall
+}
+
+// cacheCall is a request in flight which fills the cache of a key,
+// concurrent requests of the key wait for it.
+type cacheCall struct {
+	done chan struct{}
+	resp *CachedResponse // nil if the response is not cacheable
+}
+
+// cacheMiddleware returns an [http.Handler] middleware which caches the
+// responses of the route pattern routePath, route is the cached route
+// of the pattern.
+// The caller must hold t.mutex.
+func (t *Router[T]) cacheMiddleware(routePath string, route *RouteInfo, policy *CachePolicy) HTTPHandlerMiddleware {
+	if policy.TTL <= 0 {
+		panic(fmt.Sprintf("treemux: cache TTL of path %s must be positive", routePath))
+	}
+	// Routes of the same pattern share the cache, thus they must have
+	// the same policy.
+	rc := t.caches[routePath]
+	if rc != nil && !rc.policy.equal(policy) {
+		panic(fmt.Sprintf("treemux: conflicting cache policies for path %s", routePath))
+	}
+	if rc == nil {
+		store := policy.Store
+		if store == nil {
+			if t.cacheStore == nil {
+				t.cacheStore = NewLRUCacheStore(0)
+			}
+			store = t.cacheStore
+		}
+		rc = &routeCache{
+			policy: policy,
+			store:  store,
+			prefix: routePath + "\x00",
+			params: policy.Params,
+			calls:  make(map[string]*cacheCall),
+		}
+		if rc.params == nil {
+			rc.params = patternParamNames(routePath)
+		}
+		if t.caches == nil {
+			t.caches = make(map[string]*routeCache)
+		}
+		t.caches[routePath] = rc
+	}
+	policy = rc.policy
+	variant := cacheVariant(route)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+			key := rc.key(r, requestRouteData(r), variant)
+			if resp, ok := rc.store.Get(key); ok {
+				writeCachedResponse(w, resp)
+				return
+			}
+			if r.Method == http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			rc.mu.Lock()
+			if call, ok := rc.calls[key]; ok {
+				rc.mu.Unlock()
+				select {
+				case <-call.done:
+				case <-r.Context().Done():
+					return
+				}
+				if call.resp != nil {
+					writeCachedResponse(w, call.resp)
+				} else {
+					next.ServeHTTP(w, r)
+				}
+				return
+			}
+			call := &cacheCall{done: make(chan struct{})}
+			rc.calls[key] = call
+			rc.mu.Unlock()
+
+			defer func() {
+				rc.mu.Lock()
+				delete(rc.calls, key)
+				rc.mu.Unlock()
+				close(call.done)
+			}()
+
+			// Headers set by outer middlewares are not part of the response.
+			outer := w.Header().Clone()
+			cw := &responseRecorder{ResponseWriter: w, maxBodySize: policy.maxBodySize()}
+			next.ServeHTTP(cw, r)
+			if resp := rc.response(cw, outer); resp != nil {
+				rc.store.Set(key, resp)
+				call.resp = resp
+			}
+		})
+	}
+}
+
+func (p *CachePolicy) maxBodySize() int {
+	if p.MaxBodySize <= 0 {
+		return 1 << 20
+	}
+	return p.MaxBodySize
+}
+
+func (p *CachePolicy) cacheableStatus(code int) bool {
+	if p.StatusCodes == nil {
+		return code == http.StatusOK
+	}
+	for _, c := range p.StatusCodes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// key returns the cache key of a request served by the route variant,
+// see cacheVariant.
+func (rc *routeCache) key(r *http.Request, rd RouteData, variant string) string {
+	params := rd.Params()
+	var b strings.Builder
+	b.WriteString(rc.prefix)
+	for _, name := range rc.params {
+		writeCacheKeyPart(&b, name, params.Get(name))
+	}
+	// Routes of the pattern which have variants share the cache, thus
+	// the variant and the negotiated media type are part of the key.
+	b.WriteByte('@')
+	writeCacheKeyPart(&b, "variant", variant)
+	writeCacheKeyPart(&b, "type", rd.MediaType())
+	if len(rc.policy.QueryKeys) > 0 {
+		query := r.URL.Query()
+		b.WriteByte('?')
+		for _, k := range rc.policy.QueryKeys {
+			writeCacheKeyPart(&b, k, strings.Join(query[k], ","))
+		}
+	}
+	if len(rc.policy.VaryHeaders) > 0 {
+		b.WriteByte('#')
+		for _, k := range rc.policy.VaryHeaders {
+			writeCacheKeyPart(&b, k, strings.Join(r.Header.Values(k), ","))
+		}
+	}
+	return b.String()
+}
+
+// cacheVariant describes the constraints which distinguish the route
+// from the other variants of its pattern, see WithMatchers,
+// WithConsumes, Group.Version and WithSplit.
+func cacheVariant(route *RouteInfo) string {
+	var b strings.Builder
+	writeCacheKeyPart(&b, "version", route.Version)
+	writeCacheKeyPart(&b, "split", route.Variant)
+	writeCacheKeyPart(&b, "consumes", strings.Join(route.Consumes, ","))
+	for _, m := range route.Matchers {
+		writeCacheKeyPart(&b, "matcher", m.String())
+	}
+	return b.String()
+}
+
+func writeCacheKeyPart(b *strings.Builder, name, value string) {
+	b.WriteString(name)
+	b.WriteByte('=')
+	b.WriteString(strconv.Quote(value))
+	b.WriteByte('\x00')
+}
+
+// response returns the response to store, or nil if the response is
+// not cacheable. Headers which are the same as in outer are not stored.
+func (rc *routeCache) response(cw *responseRecorder, outer http.Header) *CachedResponse {
+	if cw.overflow || !rc.policy.cacheableStatus(cw.status()) {
+		return nil
+	}
+	header := cw.Header()
+	if header.Get("Set-Cookie") != "" {
+		return nil
+	}
+	for _, v := range header.Values("Cache-Control") {
+		for _, directive := range strings.Split(v, ",") {
+			switch strings.ToLower(strings.TrimSpace(directive)) {
+			case "no-store", "private":
+				return nil
+			}
+		}
+	}
+	for _, v := range header.Values("Vary") {
+		for _, name := range strings.Split(v, ",") {
+			name = strings.TrimSpace(name)
+			if name != "" && !containsHeader(rc.policy.VaryHeaders, name) {
+				return nil
+			}
+		}
+	}
+	stored := make(http.Header, len(header))
+	for k, v := range header {
+		if !equalStrings(v, outer[k]) {
+			stored[k] = append([]string(nil), v...)
+		}
+	}
+	now := time.Now()
+	return &CachedResponse{
+		StatusCode: cw.status(),
+		Header:     stored,
+		Body:       cw.body,
+		Created:    now,
+		Expires:    now.Add(rc.policy.TTL),
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func containsHeader(list []string, name string) bool {
+	for _, x := range list {
+		if strings.EqualFold(x, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// patternParamNames returns the names of the parameters of a route
+// pattern, in order.
+func patternParamNames(pattern string) []string {
+	names := []string{}
+	for _, segment := range strings.Split(pattern, "/") {
+		if len(segment) > 1 && (segment[0] == ':' || segment[0] == '*') {
+			names = append(names, segment[1:])
+		}
+	}
+	return names
+}
+
+func writeCachedResponse(w http.ResponseWriter, resp *CachedResponse) {
+	h := w.Header()
+	for k, v := range resp.Header {
+		h[k] = append([]string(nil), v...)
+	}
+	h.Set("Age", strconv.Itoa(int(time.Since(resp.Created)/time.Second)))
+	w.WriteHeader(resp.StatusCode)
+	w.Write(resp.Body)
+}
+
+// LRUCacheStore is a CacheStore in memory which evicts the least
+// recently used responses when it is full.
+type LRUCacheStore struct {
+	maxEntries int
+
+	mu      sync.Mutex
+	ll      *list.List
+	entries map[string]*list.Element
+}
+
+type lruEntry struct {
+	key  string
+	resp *CachedResponse
+}
+
+// NewLRUCacheStore creates a LRUCacheStore which stores at most
+// maxEntries responses, zero means 1024.
+func NewLRUCacheStore(maxEntries int) *LRUCacheStore {
+	if maxEntries <= 0 {
+		maxEntries = 1024
+	}
+	return &LRUCacheStore{
+		maxEntries: maxEntries,
+		ll:         list.New(),
+		entries:    make(map[string]*list.Element),
+	}
+}
+
+// Get implements CacheStore.
+func (s *LRUCacheStore) Get(key string) (*CachedResponse, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := e.Value.(*lruEntry)
+	if !time.Now().Before(entry.resp.Expires) {
+		s.remove(e)
+		return nil, false
+	}
+	s.ll.MoveToFront(e)
+	return entry.resp, true
+}
+
+// Set implements CacheStore.
+func (s *LRUCacheStore) Set(key string, resp *CachedResponse) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.entries[key]; ok {
+		e.Value.(*lruEntry).resp = resp
+		s.ll.MoveToFront(e)
+		return
+	}
+	s.entries[key] = s.ll.PushFront(&lruEntry{key: key, resp: resp})
+	for s.ll.Len() > s.maxEntries {
+		s.remove(s.ll.Back())
+	}
+}
+
+// DeletePrefix implements CacheStore.
+func (s *LRUCacheStore) DeletePrefix(prefix string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, e := range s.entries {
+		if strings.HasPrefix(key, prefix) {
+			s.remove(e)
+		}
+	}
+}
+
+// Len returns the number of stored responses.
+func (s *LRUCacheStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ll.Len()
+}
+
+func (s *LRUCacheStore) remove(e *list.Element) {
+	s.ll.Remove(e)
+	delete(s.entries, e.Value.(*lruEntry).key)
+}
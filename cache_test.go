@@ -0,0 +1,264 @@
+package treemux
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+	var calls int32
+	router := New[HandlerFunc]()
+	router.GET("/orgs/:org/repos/:repo", func(w http.ResponseWriter, r *http.Request, params Params) {
+		n := atomic.AddInt32(&calls, 1)
+		w.Header().Set("X-Call", fmt.Sprint(n))
+		fmt.Fprintf(w, "%s/%s?%s", params.Get("org"), params.Get("repo"), r.URL.Query().Get("page"))
+	}, WithCache[HandlerFunc](&CachePolicy{
+		TTL:         time.Minute,
+		QueryKeys:   []string{"page"},
+		VaryHeaders: []string{"Accept-Language"},
+	}))
+	router.GET("/users/:id", func(w http.ResponseWriter, r *http.Request, params Params) {
+		atomic.AddInt32(&calls, 1)
+		w.Write([]byte(r.URL.Query().Get("v")))
+	}, WithCache[HandlerFunc](&CachePolicy{TTL: time.Minute, Params: []string{}}))
+
+	serve := func(method, path, lang string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r, _ := newRequest(method, path, nil)
+		if lang != "" {
+			r.Header.Set("Accept-Language", lang)
+		}
+		router.ServeHTTP(w, r)
+		return w
+	}
+	expect := func(w *httptest.ResponseRecorder, body string, wantCalls int32) {
+		t.Helper()
+		if w.Code != http.StatusOK || w.Body.String() != body {
+			t.Errorf("expected 200 %q, got %d %q", body, w.Code, w.Body.String())
+		}
+		if got := atomic.LoadInt32(&calls); got != wantCalls {
+			t.Errorf("expected %d handler calls, got %d", wantCalls, got)
+		}
+	}
+
+	w := serve("GET", "/orgs/a/repos/x?page=1&ts=1", "")
+	expect(w, "a/x?1", 1)
+	if w.Header().Get("Age") != "" {
+		t.Error("expected no Age header for a miss")
+	}
+	w = serve("GET", "/orgs/a/repos/x?page=1&ts=2", "")
+	expect(w, "a/x?1", 1)
+	if w.Header().Get("Age") != "0" || w.Header().Get("X-Call") != "1" {
+		t.Errorf("expected the cached headers, got %v", w.Header())
+	}
+	expect(serve("HEAD", "/orgs/a/repos/x?page=1", ""), "", 1)
+
+	expect(serve("GET", "/orgs/a/repos/x?page=2", ""), "a/x?2", 2)
+	expect(serve("GET", "/orgs/a/repos/x?page=1", "fr"), "a/x?1", 3)
+	expect(serve("GET", "/orgs/a/repos/y?page=1", ""), "a/y?1", 4)
+	expect(serve("GET", "/orgs/b/repos/x?page=1", ""), "b/x?1", 5)
+
+	// Escaped parameter values are distinct keys.
+	expect(serve("GET", "/orgs/a%2Fb/repos/x?page=1", ""), "a/b/x?1", 6)
+	expect(serve("GET", "/orgs/c%2Fd/repos/x?page=1", ""), "c/d/x?1", 7)
+
+	// Params not selected are not part of the key.
+	expect(serve("GET", "/users/1?v=one", ""), "one", 8)
+	expect(serve("GET", "/users/2?v=two", ""), "one", 8)
+
+	// Invalidate the responses of the organization "a".
+	router.InvalidateCache("/orgs/:org/repos/:repo", map[string]string{"org": "a"})
+	expect(serve("GET", "/orgs/a/repos/x?page=1", ""), "a/x?1", 9)
+	expect(serve("GET", "/orgs/b/repos/x?page=1", ""), "b/x?1", 9)
+
+	router.InvalidateCache("/orgs/:org/repos/:repo", map[string]string{"org": "b", "repo": "x"})
+	expect(serve("GET", "/orgs/b/repos/x?page=1", ""), "b/x?1", 10)
+	expect(serve("GET", "/orgs/a/repos/x?page=1", ""), "a/x?1", 10)
+
+	router.InvalidateCache("/orgs/:org/repos/:repo", nil)
+	expect(serve("GET", "/orgs/a/repos/x?page=1", ""), "a/x?1", 11)
+
+	lr, _ := router.LookupByPath("GET", "/users/1", "/users/1")
+	if route := lr.Route(); route == nil || route.Cache == nil {
+		t.Error("expected the cache policy in route info")
+	}
+}
+
+func TestCacheNotCacheable(t *testing.T) {
+	var calls int32
+	router := New[HandlerFunc]()
+	router.UseRateLimit(&RateLimit{Limit: 100})
+	router.GET("/items/:id", func(w http.ResponseWriter, r *http.Request, params Params) {
+		atomic.AddInt32(&calls, 1)
+		switch params.Get("id") {
+		case "missing":
+			w.WriteHeader(http.StatusNotFound)
+		case "private":
+			w.Header().Set("Cache-Control", "max-age=10, private")
+		case "cookie":
+			w.Header().Set("Set-Cookie", "a=b")
+		case "vary":
+			w.Header().Set("Vary", "Authorization")
+		case "large":
+			w.Write(make([]byte, 20))
+		default:
+			w.Write([]byte("ok"))
+		}
+	}, WithCache[HandlerFunc](&CachePolicy{TTL: time.Minute, MaxBodySize: 10}))
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r, _ := newRequest("GET", path, nil)
+		router.ServeHTTP(w, r)
+		return w
+	}
+
+	for _, id := range []string{"missing", "private", "cookie", "vary", "large"} {
+		atomic.StoreInt32(&calls, 0)
+		serve("/items/" + id)
+		serve("/items/" + id)
+		if calls != 2 {
+			t.Errorf("%s: expected the response not to be cached, got %d calls", id, calls)
+		}
+	}
+
+	// Headers of outer middlewares are not cached.
+	serve("/items/1")
+	w := serve("/items/1")
+	if w.Header().Get("Age") == "" {
+		t.Fatal("expected a cached response")
+	}
+	if got := w.Header().Get("RateLimit-Remaining"); got != "88" {
+		t.Errorf("expected the current RateLimit-Remaining 88, got %q", got)
+	}
+}
+
+func TestCacheCoalescing(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	router := New[HandlerFunc]()
+	router.GET("/slow", func(w http.ResponseWriter, r *http.Request, params Params) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		w.Write([]byte("done"))
+	}, WithCache[HandlerFunc](&CachePolicy{TTL: time.Minute}))
+
+	var wg sync.WaitGroup
+	bodies := make(chan string, 5)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			r, _ := newRequest("GET", "/slow", nil)
+			router.ServeHTTP(w, r)
+			bodies <- w.Body.String()
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(bodies)
+
+	if calls != 1 {
+		t.Errorf("expected concurrent misses to be coalesced, got %d calls", calls)
+	}
+	for body := range bodies {
+		if body != "done" {
+			t.Errorf("unexpected body %q", body)
+		}
+	}
+}
+
+func TestLRUCacheStore(t *testing.T) {
+	store := NewLRUCacheStore(2)
+	fresh := &CachedResponse{Expires: time.Now().Add(time.Minute)}
+	store.Set("a", fresh)
+	store.Set("b", fresh)
+	store.Get("a")
+	store.Set("c", fresh)
+	if _, ok := store.Get("b"); ok {
+		t.Error("expected the least recently used entry to be evicted")
+	}
+	if _, ok := store.Get("a"); !ok {
+		t.Error("expected a to be kept")
+	}
+
+	store.Set("d", &CachedResponse{Expires: time.Now().Add(-time.Second)})
+	if _, ok := store.Get("d"); ok {
+		t.Error("expected the expired entry not to be returned")
+	}
+	if store.Len() != 1 {
+		t.Errorf("expected 1 entry, got %d", store.Len())
+	}
+}
+
+func TestCacheConflictingPolicies(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request, params Params) {}
+	router := New[HandlerFunc]()
+	router.GET("/a/:id", handler, WithCache[HandlerFunc](&CachePolicy{TTL: time.Minute, QueryKeys: []string{"q"}}))
+	router.HEAD("/a/:id", handler, WithCache[HandlerFunc](&CachePolicy{TTL: time.Minute, QueryKeys: []string{"q"}}))
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic for conflicting cache policies")
+		}
+	}()
+	router.POST("/a/:id", handler, WithCache[HandlerFunc](&CachePolicy{TTL: time.Minute, Params: []string{}}))
+}
+
+func TestCacheInvalidPolicy(t *testing.T) {
+	opt := WithCache[HandlerFunc](nil)
+	defer func() {
+		if err := recover(); err == nil || !strings.Contains(fmt.Sprint(err), "/items") {
+			t.Errorf("expected a panic naming the route, got %v", err)
+		}
+	}()
+	New[HandlerFunc]().GET("/items", func(w http.ResponseWriter, r *http.Request, params Params) {}, opt)
+}
+
+func TestCacheVariants(t *testing.T) {
+	policy := &CachePolicy{TTL: time.Minute}
+	reply := func(body string) HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request, params Params) {
+			w.Write([]byte(body + " " + r.Header.Get("Accept")))
+		}
+	}
+	router := New[HandlerFunc]()
+	router.GET("/items/:id", reply("v1"), WithVersion[HandlerFunc]("1", false), WithCache[HandlerFunc](policy))
+	router.GET("/items/:id", reply("v2"), WithVersion[HandlerFunc]("2", false), WithCache[HandlerFunc](policy),
+		WithProduces[HandlerFunc]("application/json", "text/html"))
+	router.GET("/users/:id", reply("beta"), WithMatchers[HandlerFunc](RequireHeader("X-Beta", "1")), WithCache[HandlerFunc](policy))
+	router.GET("/users/:id", reply("stable"), WithCache[HandlerFunc](policy))
+
+	tests := []struct {
+		path   string
+		header map[string]string
+		want   string
+	}{
+		{"/items/1", map[string]string{"X-API-Version": "1"}, "v1 "},
+		{"/items/1", map[string]string{"X-API-Version": "2", "Accept": "text/html"}, "v2 text/html"},
+		{"/items/1", map[string]string{"X-API-Version": "2", "Accept": "application/json"}, "v2 application/json"},
+		{"/users/1", map[string]string{"X-Beta": "1"}, "beta "},
+		{"/users/1", nil, "stable "},
+	}
+	for i := 0; i < 2; i++ {
+		for _, tt := range tests {
+			w := httptest.NewRecorder()
+			r, _ := newRequest("GET", tt.path, nil)
+			for k, v := range tt.header {
+				r.Header.Set(k, v)
+			}
+			router.ServeHTTP(w, r)
+			if got := w.Body.String(); got != tt.want {
+				t.Errorf("%s %v: expected %q, got %q", tt.path, tt.header, tt.want, got)
+			}
+		}
+	}
+}
@@ -35,6 +35,71 @@ type ContextData interface {
 	Params() Params
 }
 
+type routeData struct {
+	contextData
+	version      string
+	variant      string
+	mediaType    string
+	mountPath    string
+	locale       string
+	implicitHead bool
+}
+
+func (rd *routeData) Version() string {
+	return rd.version
+}
+
+func (rd *routeData) Variant() string {
+	return rd.variant
+}
+
+func (rd *routeData) MediaType() string {
+	return rd.mediaType
+}
+
+func (rd *routeData) IsImplicitHead() bool {
+	return rd.implicitHead
+}
+
+func (rd *routeData) MountPath() string {
+	return rd.mountPath
+}
+
+func (rd *routeData) Locale() string {
+	return rd.locale
+}
+
+// RouteData is the ContextData added by the router, it reports more
+// information about the matched route. It is separated from
+// ContextData, thus other implementations of ContextData are not
+// required to implement it, see GetRouteData.
+type RouteData interface {
+	ContextData
+
+	// Version returns the API version of the matched route.
+	Version() string
+
+	// Variant returns the traffic split variant of the matched route.
+	Variant() string
+
+	// MediaType returns the media type selected by content negotiation.
+	MediaType() string
+
+	// IsImplicitHead tells whether the request is a HEAD request served
+	// by the GET handler. The response body is discarded in this case,
+	// handlers may check this to skip expensive work.
+	IsImplicitHead() bool
+
+	// MountPath returns the path prefix stripped by Group.Mount, it is
+	// empty if the request is not served by a mounted handler.
+	// It can be used to build absolute URLs in mounted handlers.
+	MountPath() string
+
+	// Locale returns the locale of the request, see
+	// Group.HandleLocalized and Router.LocaleExtractor.
+	Locale() string
+}
+
 // NewContextData creates a new ContextData.
 func NewContextData(route string, params Params) ContextData {
 	return &contextData{
@@ -49,6 +114,17 @@ func GetContextData(r *http.Request) ContextData {
 	return getDataFromContext(r.Context())
 }
 
+// GetRouteData returns the RouteData associated with the request.
+// If the request's ContextData does not implement RouteData, the
+// returned RouteData reports only the ContextData's route and params.
+func GetRouteData(r *http.Request) RouteData {
+	cd := getDataFromContext(r.Context())
+	if rd, ok := cd.(RouteData); ok {
+		return rd
+	}
+	return &routeData{contextData: contextData{route: cd.Route(), params: cd.Params()}}
+}
+
 func getDataFromContext(ctx context.Context) ContextData {
 	if p, ok := ctx.Value(contextDataKey).(ContextData); ok {
 		return p
@@ -88,3 +164,21 @@ type contextKey int
 // contextDataKey is used to retrieve the path's params and matched route
 // from a request's context.
 const contextDataKey contextKey = 0
+
+type requestDataKey struct{}
+
+// requestRouteData returns the RouteData of the request, which is passed
+// to the routes which need it by Router.ServeLookupResult, or else taken
+// from the request's ContextData.
+func requestRouteData(r *http.Request) RouteData {
+	if rd, ok := r.Context().Value(requestDataKey{}).(*routeData); ok {
+		return rd
+	}
+	return GetRouteData(r)
+}
+
+// requestParams returns the path parameters of the request,
+// see requestRouteData.
+func requestParams(r *http.Request) Params {
+	return requestRouteData(r).Params()
+}
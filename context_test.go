@@ -229,3 +229,17 @@ func toHandlerFunc(f http.HandlerFunc) func(http.ResponseWriter, *http.Request,
 		f(w, r)
 	}
 }
+
+func TestGetRouteData(t *testing.T) {
+	r, _ := http.NewRequest("GET", "/", nil)
+	r = AddContextData(r, NewContextData("/users/:id", Params{Keys: []string{"id"}, Values: []string{"1"}}))
+	rd := GetRouteData(r)
+	if rd.Route() != "/users/:id" || rd.Param("id") != "1" || rd.Version() != "" || rd.MountPath() != "" {
+		t.Errorf("unexpected route data %+v", rd)
+	}
+
+	r = AddContextData(r, &routeData{contextData: contextData{route: "/x"}, locale: "de", implicitHead: true})
+	if rd := GetRouteData(r); rd.Route() != "/x" || rd.Locale() != "de" || !rd.IsImplicitHead() {
+		t.Errorf("unexpected route data %+v", rd)
+	}
+}
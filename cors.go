@@ -0,0 +1,221 @@
+package treemux
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSPolicy configures Cross-Origin Resource Sharing for routes.
+//
+// A policy can be set to a group by Group.UseCORS, or to a single route
+// by WithCORS. When a route has a policy, the router answers preflight
+// OPTIONS requests automatically using the methods registered for the
+// matched path, unless an OPTIONS handler is registered for the path,
+// and adds the `Access-Control-*` headers to actual responses.
+type CORSPolicy struct {
+	// AllowOrigins is the list of origins a cross-domain request can be
+	// executed from. "*" allows any origin, an origin may contain
+	// wildcards, e.g. "https://*.example.com".
+	AllowOrigins []string
+
+	// AllowOriginFunc, if not nil, is called to validate an origin which
+	// does not match AllowOrigins.
+	AllowOriginFunc func(origin string) bool
+
+	// AllowHeaders is the list of non-simple headers the client is allowed
+	// to use. If it is empty, the headers in `Access-Control-Request-Headers`
+	// are reflected.
+	AllowHeaders []string
+
+	// ExposeHeaders is the list of headers which are safe to expose to
+	// the client.
+	ExposeHeaders []string
+
+	// AllowCredentials indicates whether the request can include user
+	// credentials like cookies or TLS client certificates.
+	AllowCredentials bool
+
+	// MaxAge tells how long the results of a preflight request can be
+	// cached by the client. Zero means no `Access-Control-Max-Age` header
+	// is sent.
+	MaxAge time.Duration
+}
+
+func (p *CORSPolicy) isOriginAllowed(origin string) bool {
+	for _, o := range p.AllowOrigins {
+		if o == "*" || o == origin {
+			return true
+		}
+		if strings.Contains(o, "*") && matchGlob(o, origin) {
+			return true
+		}
+	}
+	return p.AllowOriginFunc != nil && p.AllowOriginFunc(origin)
+}
+
+func (p *CORSPolicy) allowAnyOrigin() bool {
+	for _, o := range p.AllowOrigins {
+		if o == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *CORSPolicy) setOriginHeaders(header http.Header, origin string) {
+	if p.allowAnyOrigin() && !p.AllowCredentials {
+		header.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		header.Set("Access-Control-Allow-Origin", origin)
+	}
+	if p.AllowCredentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+// PreflightHeaders returns the response headers for a preflight request.
+// allowedMethods is the method set registered for the request path.
+// It returns false if the origin, the requested method or the requested
+// headers are not allowed.
+func (p *CORSPolicy) PreflightHeaders(origin, requestMethod, requestHeaders string, allowedMethods []string) (http.Header, bool) {
+	header := make(http.Header)
+	header.Add("Vary", "Origin")
+	header.Add("Vary", "Access-Control-Request-Method")
+	header.Add("Vary", "Access-Control-Request-Headers")
+	if !p.isOriginAllowed(origin) {
+		return header, false
+	}
+	if !containsString(allowedMethods, requestMethod) {
+		return header, false
+	}
+	if len(p.AllowHeaders) > 0 && requestHeaders != "" {
+		for _, h := range strings.Split(requestHeaders, ",") {
+			h = strings.TrimSpace(h)
+			if h != "" && !containsFold(p.AllowHeaders, h) {
+				return header, false
+			}
+		}
+	}
+
+	p.setOriginHeaders(header, origin)
+	header.Set("Access-Control-Allow-Methods", strings.Join(allowedMethods, ", "))
+	if len(p.AllowHeaders) > 0 {
+		header.Set("Access-Control-Allow-Headers", strings.Join(p.AllowHeaders, ", "))
+	} else if requestHeaders != "" {
+		header.Set("Access-Control-Allow-Headers", requestHeaders)
+	}
+	if p.MaxAge > 0 {
+		header.Set("Access-Control-Max-Age", strconv.Itoa(int(p.MaxAge/time.Second)))
+	}
+	return header, true
+}
+
+// ResponseHeaders returns the headers to add to an actual response.
+func (p *CORSPolicy) ResponseHeaders(origin string) http.Header {
+	header := make(http.Header)
+	if !p.allowAnyOrigin() || p.AllowCredentials {
+		// The response varies with the request origin.
+		header.Set("Vary", "Origin")
+	}
+	if origin == "" || !p.isOriginAllowed(origin) {
+		return header
+	}
+	p.setOriginHeaders(header, origin)
+	if len(p.ExposeHeaders) > 0 {
+		header.Set("Access-Control-Expose-Headers", strings.Join(p.ExposeHeaders, ", "))
+	}
+	return header
+}
+
+// UseCORS sets the CORS policy for routes added to the group afterwards.
+// Sub-groups created afterwards inherit the policy.
+func (g *Group[T]) UseCORS(policy *CORSPolicy) {
+	g.cors = policy
+}
+
+// WithCORS returns a RouteOption which sets the CORS policy for a single
+// route, it overrides the policy of the group.
+func WithCORS[T HandlerConstraint](policy *CORSPolicy) RouteOption[T] {
+	return func(opts *routeOptions[T]) {
+		opts.cors = policy
+	}
+}
+
+// CORSHeaders computes the CORS response headers for a request given
+// the lookup result. getHeader returns the request header value by key,
+// it allows frameworks other than net/http to use the CORS feature.
+//
+// If preflight is true, the request is a preflight request which is
+// answered by the router, the caller should write the headers with
+// status code 204 and not call the handler.
+// Else if the returned header is not nil, the caller should add them
+// to the response before calling the handler.
+func (t *Router[T]) CORSHeaders(lr LookupResult[T], method string, getHeader func(key string) string) (header http.Header, preflight bool) {
+	n := lr.node
+	if n == nil || !n.hasCORS {
+		return nil, false
+	}
+
+	origin := getHeader("Origin")
+	requestMethod := getHeader("Access-Control-Request-Method")
+	if method == http.MethodOptions && origin != "" && requestMethod != "" &&
+		!t.Bridge.IsHandlerValid(n.leafHandlers[http.MethodOptions]) {
+		policy := n.corsPolicy(requestMethod)
+		if policy == nil {
+			return nil, false
+		}
+		allowed := n.allowedMethods()
+		if _, ok := n.leafHandlers[MethodAny]; ok && !containsString(allowed, requestMethod) {
+			allowed = append(allowed, requestMethod)
+		}
+		header, _ = policy.PreflightHeaders(origin, requestMethod, getHeader("Access-Control-Request-Headers"), allowed)
+		return header, true
+	}
+
+	policy := n.corsPolicy(method)
+	if policy == nil {
+		return nil, false
+	}
+	return policy.ResponseHeaders(origin), false
+}
+
+func (t *Router[T]) serveCORS(w http.ResponseWriter, r *http.Request, lr LookupResult[T]) (done bool) {
+	header, preflight := t.CORSHeaders(lr, r.Method, r.Header.Get)
+	dst := w.Header()
+	for k, v := range header {
+		dst[k] = append(dst[k], v...)
+	}
+	if preflight {
+		w.WriteHeader(http.StatusNoContent)
+		return true
+	}
+	return false
+}
+
+// corsPolicy returns the CORS policy of the route which serves method.
+func (n *node[T]) corsPolicy(method string) *CORSPolicy {
+	if route := n.getRoute(method); route != nil {
+		return route.CORS
+	}
+	return nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(list []string, s string) bool {
+	for _, x := range list {
+		if strings.EqualFold(x, s) {
+			return true
+		}
+	}
+	return false
+}
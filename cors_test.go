@@ -0,0 +1,124 @@
+package treemux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCORSPreflight(t *testing.T) {
+	router := New[HandlerFunc]()
+	api := router.NewGroup("/api")
+	api.UseCORS(&CORSPolicy{
+		AllowOrigins: []string{"https://*.example.com"},
+		AllowHeaders: []string{"Content-Type", "Authorization"},
+		MaxAge:       10 * time.Minute,
+	})
+	api.GET("/users/:id", simpleHandler)
+	api.PUT("/users/:id", simpleHandler)
+	api.DELETE("/users/:id", simpleHandler, WithCORS[HandlerFunc](&CORSPolicy{
+		AllowOrigins:     []string{"https://admin.example.org"},
+		AllowCredentials: true,
+	}))
+	router.GET("/no-cors", simpleHandler)
+
+	preflight := func(path, origin, method, headers string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r, _ := newRequest("OPTIONS", path, nil)
+		r.Header.Set("Origin", origin)
+		r.Header.Set("Access-Control-Request-Method", method)
+		if headers != "" {
+			r.Header.Set("Access-Control-Request-Headers", headers)
+		}
+		router.ServeHTTP(w, r)
+		return w
+	}
+
+	w := preflight("/api/users/1", "https://app.example.com", "PUT", "content-type")
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected status 204, got %d", w.Code)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+		t.Errorf("unexpected Access-Control-Allow-Origin %q", got)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Methods"); got != "DELETE, GET, HEAD, PUT" {
+		t.Errorf("unexpected Access-Control-Allow-Methods %q", got)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Headers"); got != "Content-Type, Authorization" {
+		t.Errorf("unexpected Access-Control-Allow-Headers %q", got)
+	}
+	if got := w.Header().Get("Access-Control-Max-Age"); got != "600" {
+		t.Errorf("unexpected Access-Control-Max-Age %q", got)
+	}
+
+	w = preflight("/api/users/1", "https://evil.com", "PUT", "")
+	if w.Code != http.StatusNoContent || w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("disallowed origin should not get CORS headers, got %d %v", w.Code, w.Header())
+	}
+
+	w = preflight("/api/users/1", "https://app.example.com", "PUT", "X-Custom")
+	if w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("disallowed header should not get CORS headers, got %v", w.Header())
+	}
+
+	w = preflight("/api/users/1", "https://admin.example.org", "DELETE", "")
+	if w.Header().Get("Access-Control-Allow-Origin") != "https://admin.example.org" ||
+		w.Header().Get("Access-Control-Allow-Credentials") != "true" {
+		t.Errorf("per-route policy is not applied, got %v", w.Header())
+	}
+
+	w = preflight("/no-cors", "https://app.example.com", "GET", "")
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected status 405 for route without CORS, got %d", w.Code)
+	}
+}
+
+func TestCORSActualResponse(t *testing.T) {
+	router := New[HandlerFunc]()
+	router.UseCORS(&CORSPolicy{
+		AllowOrigins:  []string{"*"},
+		ExposeHeaders: []string{"X-Request-Id"},
+	})
+	router.GET("/public", simpleHandler)
+	router.NewGroup("/private").GET("/data", simpleHandler, WithCORS[HandlerFunc](&CORSPolicy{
+		AllowOrigins:     []string{"https://app.example.com"},
+		AllowCredentials: true,
+	}))
+
+	w := httptest.NewRecorder()
+	r, _ := newRequest("GET", "/public", nil)
+	r.Header.Set("Origin", "https://any.example.net")
+	router.ServeHTTP(w, r)
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("unexpected Access-Control-Allow-Origin %q", got)
+	}
+	if got := w.Header().Get("Access-Control-Expose-Headers"); got != "X-Request-Id" {
+		t.Errorf("unexpected Access-Control-Expose-Headers %q", got)
+	}
+	if got := w.Header().Get("Vary"); got != "" {
+		t.Errorf("unexpected Vary %q", got)
+	}
+
+	w = httptest.NewRecorder()
+	r, _ = newRequest("GET", "/private/data", nil)
+	r.Header.Set("Origin", "https://app.example.com")
+	router.ServeHTTP(w, r)
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+		t.Errorf("unexpected Access-Control-Allow-Origin %q", got)
+	}
+	if got := w.Header().Get("Vary"); got != "Origin" {
+		t.Errorf("unexpected Vary %q", got)
+	}
+
+	w = httptest.NewRecorder()
+	r, _ = newRequest("HEAD", "/private/data", nil)
+	r.Header.Set("Origin", "https://other.example.com")
+	router.ServeHTTP(w, r)
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("unexpected Access-Control-Allow-Origin %q", got)
+	}
+	if got := w.Header().Get("Vary"); got != "Origin" {
+		t.Errorf("unexpected Vary %q", got)
+	}
+}
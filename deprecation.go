@@ -0,0 +1,232 @@
+package treemux
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Deprecation describes the deprecation of a route, see WithDeprecation.
+type Deprecation struct {
+	// Since is the time since when the route is deprecated, it is sent
+	// in the `Deprecation` header as "@<unix seconds>", see RFC 9745.
+	// If it is zero, the header value is "true".
+	Since time.Time
+
+	// Sunset, if not zero, is the time after which the route will not
+	// be available, it is sent in the `Sunset` header, see RFC 8594.
+	Sunset time.Time
+
+	// Successor, if not empty, is the path template of the replacement
+	// of the route, sent in the header
+	// `Link: <Successor>; rel="successor-version"`.
+	// It is a path or an URL, segments in the form ":name" and "*name"
+	// are replaced by the escaped path parameters of the request, e.g.
+	// "/v2/users/:id".
+	Successor string
+
+	// SuccessorRoute, if not empty, is the name of the replacement route,
+	// see Group.HandleLocalized. It takes precedence over Successor, the
+	// URL of the route is generated by Router.URL with the locale and the
+	// path parameters of the request.
+	SuccessorRoute string
+
+	// GoneAfterSunset tells to respond 410 Gone, with the deprecation
+	// headers, instead of calling the handler after the sunset time.
+	GoneAfterSunset bool
+}
+
+// DeprecatedRoute is the usage of a deprecated route, see
+// Router.DeprecatedRoutes.
+type DeprecatedRoute struct {
+	Method string
+	Path   string
+
+	// Deprecation is nil if the route is deprecated by its API
+	// version, see Group.DeprecatedVersion.
+	Deprecation *Deprecation
+
+	// Calls is the number of requests served by the route since it
+	// was registered.
+	Calls uint64
+}
+
+// WithDeprecation returns a RouteOption which marks the route as
+// deprecated. Responses of the route have the `Deprecation` header,
+// and the `Sunset` and `Link` headers if Sunset and Successor are set.
+// The calls of deprecated routes are counted, see
+// Router.DeprecatedRoutes, and the routes are reported as deprecated
+// OpenAPI operations by Router.OpenAPIDeprecations.
+func WithDeprecation[T HandlerConstraint](d *Deprecation) RouteOption[T] {
+	return func(opts *routeOptions[T]) {
+		opts.deprecation = d
+	}
+}
+
+// DeprecatedCalls returns the number of requests served by a deprecated
+// route, it is always zero if the route is not deprecated.
+func (r *Route[T]) DeprecatedCalls() uint64 {
+	if r == nil || r.state == nil {
+		return 0
+	}
+	return atomic.LoadUint64(&r.state.deprecatedCalls)
+}
+
+// DeprecatedRoutes returns the deprecated routes with their calls,
+// sorted by path and method.
+func (t *Router[T]) DeprecatedRoutes() []DeprecatedRoute {
+	var out []DeprecatedRoute
+	for _, route := range t.Routes() {
+		if route.Deprecation == nil && !route.VersionDeprecated {
+			continue
+		}
+		out = append(out, DeprecatedRoute{
+			Method:      route.Method,
+			Path:        route.Path,
+			Deprecation: route.Deprecation,
+			Calls:       route.DeprecatedCalls(),
+		})
+	}
+	return out
+}
+
+// OpenAPIDeprecations returns the deprecated routes as the "paths" of
+// an OpenAPI document, to be merged into a generated document, e.g.
+//
+//	{"/v1/users/{id}": {"get": {
+//		"deprecated":         true,
+//		"x-deprecated-since": "2024-01-01T00:00:00Z",
+//		"x-sunset":           "2099-06-30T23:59:59Z",
+//		"x-successor":        "/v2/users/{id}",
+//	}}}
+//
+// The extensions are set if the fields of Deprecation are set.
+// Path parameters are written as "{name}", regular expression segments
+// are kept as is. Routes registered by Group.Any are reported for all
+// the methods of the OpenAPI path item.
+func (t *Router[T]) OpenAPIDeprecations() map[string]map[string]map[string]any {
+	paths := make(map[string]map[string]map[string]any)
+	for _, route := range t.DeprecatedRoutes() {
+		op := map[string]any{"deprecated": true}
+		if d := route.Deprecation; d != nil {
+			if !d.Since.IsZero() {
+				op["x-deprecated-since"] = d.Since.UTC().Format(time.RFC3339)
+			}
+			if !d.Sunset.IsZero() {
+				op["x-sunset"] = d.Sunset.UTC().Format(time.RFC3339)
+			}
+			if successor := t.successorPattern(d); successor != "" {
+				op["x-successor"] = openAPIPath(successor)
+			}
+		}
+		path := openAPIPath(route.Path)
+		item := paths[path]
+		if item == nil {
+			item = make(map[string]map[string]any)
+			paths[path] = item
+		}
+		if route.Method != MethodAny {
+			item[strings.ToLower(route.Method)] = op
+			continue
+		}
+		for _, method := range openAPIMethods {
+			if _, ok := item[method]; !ok {
+				item[method] = op
+			}
+		}
+	}
+	return paths
+}
+
+var openAPIMethods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// openAPIPath converts the parameters of a route pattern to the OpenAPI
+// form "{name}".
+func openAPIPath(pattern string) string {
+	segments := strings.Split(pattern, "/")
+	for i, seg := range segments {
+		if seg == "" {
+			continue
+		}
+		switch seg[0] {
+		case ':', '*':
+			segments[i] = "{" + seg[1:] + "}"
+		case '\\':
+			segments[i] = seg[1:]
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// serveDeprecation counts the call of a deprecated route and writes
+// the deprecation headers. It returns true if the request is answered
+// with 410 Gone.
+func (t *Router[T]) serveDeprecation(w http.ResponseWriter, lr LookupResult[T]) bool {
+	if gone := t.setDeprecationHeaders(w.Header(), lr); gone {
+		w.WriteHeader(http.StatusGone)
+		return true
+	}
+	return false
+}
+
+// setDeprecationHeaders counts the call of a deprecated route and sets
+// the deprecation headers to h. It returns true if the route is gone
+// after its sunset.
+func (t *Router[T]) setDeprecationHeaders(h http.Header, lr LookupResult[T]) (gone bool) {
+	route := lr.route
+	if route.state != nil {
+		atomic.AddUint64(&route.state.deprecatedCalls, 1)
+	}
+	d := route.Deprecation
+	if d == nil {
+		h.Set("Deprecation", "true")
+		return false
+	}
+
+	if d.Since.IsZero() {
+		h.Set("Deprecation", "true")
+	} else {
+		h.Set("Deprecation", "@"+strconv.FormatInt(d.Since.Unix(), 10))
+	}
+	if !d.Sunset.IsZero() {
+		h.Set("Sunset", d.Sunset.UTC().Format(http.TimeFormat))
+	}
+	if successor := t.successorURL(d, lr); successor != "" {
+		h.Add("Link", "<"+successor+`>; rel="successor-version"`)
+	}
+	return d.GoneAfterSunset && !d.Sunset.IsZero() && !time.Now().Before(d.Sunset)
+}
+
+// successorURL returns the URL of the replacement of a deprecated route
+// for the request of lr, it is empty if the URL can not be generated.
+func (t *Router[T]) successorURL(d *Deprecation, lr LookupResult[T]) string {
+	if d.SuccessorRoute == "" {
+		if d.Successor == "" {
+			return ""
+		}
+		return escapeTargetPath(d.Successor, lr.Params)
+	}
+	pairs := make([]string, 0, 2*len(lr.Params.Keys))
+	for i, key := range lr.Params.Keys {
+		pairs = append(pairs, key, lr.Params.Values[i])
+	}
+	u, err := t.URL(d.SuccessorRoute, lr.Locale, pairs...)
+	if err != nil {
+		return ""
+	}
+	return u
+}
+
+// successorPattern returns the pattern of the replacement of a
+// deprecated route, the pattern of Router.DefaultLocale is used for
+// SuccessorRoute.
+func (t *Router[T]) successorPattern(d *Deprecation) string {
+	if d.SuccessorRoute == "" {
+		return d.Successor
+	}
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	return t.localized[d.SuccessorRoute][t.DefaultLocale]
+}
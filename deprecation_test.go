@@ -0,0 +1,150 @@
+package treemux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDeprecation(t *testing.T) {
+	since := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	sunset := time.Date(2099, 6, 30, 23, 59, 59, 0, time.UTC)
+
+	router := New[HandlerFunc]()
+	handler := func(w http.ResponseWriter, r *http.Request, params Params) {}
+	router.GET("/v1/users/:id", handler, WithDeprecation[HandlerFunc](&Deprecation{
+		Since:     since,
+		Sunset:    sunset,
+		Successor: "/v2/users/:id",
+	}))
+	router.GET("/v1/legacy", handler, WithDeprecation[HandlerFunc](&Deprecation{
+		Sunset:          time.Now().Add(-time.Hour),
+		GoneAfterSunset: true,
+	}))
+	router.GET("/v2/users/:id", handler)
+	router.DeprecatedVersion("1").GET("/items", handler)
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r, _ := newRequest("GET", path, nil)
+		r.Header.Set("Accept-Version", "1")
+		router.ServeHTTP(w, r)
+		return w
+	}
+
+	w := serve("/v1/users/42")
+	if w.Code != http.StatusOK {
+		t.Errorf("expected 200, got %d", w.Code)
+	}
+	if got := w.Header().Get("Deprecation"); got != "@1704067200" {
+		t.Errorf("unexpected Deprecation header %q", got)
+	}
+	if got := w.Header().Get("Sunset"); got != "Tue, 30 Jun 2099 23:59:59 GMT" {
+		t.Errorf("unexpected Sunset header %q", got)
+	}
+	if got := w.Header().Get("Link"); got != `</v2/users/42>; rel="successor-version"` {
+		t.Errorf("unexpected Link header %q", got)
+	}
+	w = serve("/v1/users/a%2Fb%3E%3B")
+	if got := w.Header().Get("Link"); got != `</v2/users/a%2Fb%3E%3B>; rel="successor-version"` {
+		t.Errorf("expected escaped params in the Link header, got %q", got)
+	}
+
+	w = serve("/v1/legacy")
+	if w.Code != http.StatusGone || w.Header().Get("Deprecation") != "true" || w.Header().Get("Sunset") == "" {
+		t.Errorf("expected 410 with deprecation headers, got %d %v", w.Code, w.Header())
+	}
+
+	if w := serve("/v2/users/42"); w.Header().Get("Deprecation") != "" {
+		t.Errorf("expected no Deprecation header, got %q", w.Header().Get("Deprecation"))
+	}
+	if w := serve("/items"); w.Header().Get("Deprecation") != "true" {
+		t.Errorf("expected Deprecation true for a deprecated version, got %q", w.Header().Get("Deprecation"))
+	}
+
+	routes := router.DeprecatedRoutes()
+	want := []struct {
+		path  string
+		calls uint64
+	}{
+		{"/items", 1},
+		{"/v1/legacy", 1},
+		{"/v1/users/:id", 2},
+	}
+	if len(routes) != len(want) {
+		t.Fatalf("expected %d deprecated routes, got %v", len(want), routes)
+	}
+	for i, r := range routes {
+		if r.Path != want[i].path || r.Calls != want[i].calls {
+			t.Errorf("expected %s with %d calls, got %s with %d calls", want[i].path, want[i].calls, r.Path, r.Calls)
+		}
+	}
+	if routes[0].Deprecation != nil || routes[2].Deprecation.Successor != "/v2/users/:id" {
+		t.Errorf("unexpected deprecation info %+v", routes)
+	}
+}
+
+func TestOpenAPIDeprecations(t *testing.T) {
+	router := New[HandlerFunc]()
+	handler := func(w http.ResponseWriter, r *http.Request, params Params) {}
+	router.GET("/v1/users/:id", handler, WithDeprecation[HandlerFunc](&Deprecation{
+		Since:     time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		Sunset:    time.Date(2099, 6, 30, 23, 59, 59, 0, time.UTC),
+		Successor: "/v2/users/:id",
+	}))
+	router.Any("/v1/files/*path", handler, WithDeprecation[HandlerFunc](&Deprecation{}))
+	router.DELETE("/v1/files/*path", handler, WithDeprecation[HandlerFunc](&Deprecation{Successor: "/v2/files"}))
+	router.GET("/v2/users/:id", handler)
+
+	paths := router.OpenAPIDeprecations()
+	if len(paths) != 2 {
+		t.Fatalf("expected 2 deprecated paths, got %v", paths)
+	}
+	got := paths["/v1/users/{id}"]["get"]
+	want := map[string]any{
+		"deprecated":         true,
+		"x-deprecated-since": "2024-01-01T00:00:00Z",
+		"x-sunset":           "2099-06-30T23:59:59Z",
+		"x-successor":        "/v2/users/{id}",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected operation %v", got)
+	}
+	files := paths["/v1/files/{path}"]
+	if len(files) != 8 || files["get"]["deprecated"] != true || files["delete"]["x-successor"] != "/v2/files" {
+		t.Errorf("unexpected path item %v", files)
+	}
+}
+
+func TestDeprecationSuccessorRoute(t *testing.T) {
+	router := New[HandlerFunc]()
+	router.DefaultLocale = "en"
+	router.LocaleExtractor = LocaleFromPathPrefix("en", "de")
+	handler := func(w http.ResponseWriter, r *http.Request, params Params) {}
+	router.HandleLocalized("GET", "user", map[string]string{
+		"en": "/en/v2/users/:id",
+		"de": "/de/v2/benutzer/:id",
+	}, handler)
+	router.GET("/:lang/v1/users/:id", handler, WithDeprecation[HandlerFunc](&Deprecation{
+		Successor:      "/ignored",
+		SuccessorRoute: "user",
+	}))
+
+	for path, want := range map[string]string{
+		"/de/v1/users/a%20b": `</de/v2/benutzer/a%20b>; rel="successor-version"`,
+		"/fr/v1/users/1":     `</en/v2/users/1>; rel="successor-version"`,
+	} {
+		w := httptest.NewRecorder()
+		r, _ := newRequest("GET", path, nil)
+		router.ServeHTTP(w, r)
+		if got := w.Header().Get("Link"); got != want {
+			t.Errorf("%s: expected Link %q, got %q", path, want, got)
+		}
+	}
+	op := router.OpenAPIDeprecations()["/{lang}/v1/users/{id}"]["get"]
+	if op["x-successor"] != "/en/v2/users/{id}" {
+		t.Errorf("unexpected OpenAPI successor %v", op["x-successor"])
+	}
+}
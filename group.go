@@ -4,21 +4,65 @@ import (
 	"fmt"
 	"net/url"
 	"strings"
+	"time"
 )
 
+// MethodAny is the method of routes registered by Group.Any,
+// such routes match requests of any method which does not have
+// a handler registered explicitly.
+const MethodAny = "*"
+
 type MiddlewareFunc[T HandlerConstraint] func(next T) T
 
-func withMiddlewares[T HandlerConstraint](handler T, stack []MiddlewareFunc[T]) T {
+// Middleware is a MiddlewareFunc with an optional name.
+// A named middleware can be identified in route introspection and Dump,
+// and it can be excluded from a single route by WithoutMiddlewares.
+type Middleware[T HandlerConstraint] struct {
+	Name string
+	Func MiddlewareFunc[T]
+
+	// Predicate, if not nil, is checked when a route is added,
+	// the middleware is applied to the route only if it returns true.
+	Predicate RoutePredicate
+}
+
+// String returns the name of the middleware, if the middleware is
+// anonymous, it returns the function name of Func.
+func (m Middleware[T]) String() string {
+	if m.Name != "" {
+		return m.Name
+	}
+	return getFuncName(m.Func)
+}
+
+func withMiddlewares[T HandlerConstraint](handler T, stack []Middleware[T]) T {
 	for i := len(stack) - 1; i >= 0; i-- {
-		handler = stack[i](handler)
+		handler = stack[i].Func(handler)
 	}
 	return handler
 }
 
+func toMiddlewares[T HandlerConstraint](middlewares []MiddlewareFunc[T]) []Middleware[T] {
+	out := make([]Middleware[T], 0, len(middlewares))
+	for _, mw := range middlewares {
+		out = append(out, Middleware[T]{Func: mw})
+	}
+	return out
+}
+
 type Group[T HandlerConstraint] struct {
 	path  string
 	mux   *Router[T]
-	stack []MiddlewareFunc[T]
+	stack []Middleware[T]
+	cors  *CORSPolicy
+
+	version           string
+	versionDeprecated bool
+
+	rateLimit *RateLimit
+
+	timeout     time.Duration
+	maxBodySize int64
 }
 
 // NewGroup adds a new sub-group to this group.
@@ -37,12 +81,75 @@ func (g *Group[T]) NewGroup(path string) *Group[T] {
 		path:  path,
 		mux:   g.mux,
 		stack: g.stack[:len(g.stack):len(g.stack)],
+		cors:  g.cors,
+
+		version:           g.version,
+		versionDeprecated: g.versionDeprecated,
+
+		rateLimit: g.rateLimit,
+
+		timeout:     g.timeout,
+		maxBodySize: g.maxBodySize,
 	}
 }
 
 // Use appends a middleware handler to the Group middleware stack.
 func (g *Group[T]) Use(middlewares ...MiddlewareFunc[T]) {
-	g.stack = append(g.stack, middlewares...)
+	g.stack = append(g.stack, toMiddlewares(middlewares)...)
+}
+
+// UseNamed appends a named middleware handler to the Group middleware stack.
+func (g *Group[T]) UseNamed(name string, middleware MiddlewareFunc[T]) {
+	g.stack = append(g.stack, Middleware[T]{Name: name, Func: middleware})
+}
+
+// UseIf appends middlewares to the Group middleware stack, which are
+// applied only to routes that match the predicate.
+// The predicate is checked when a route is added, thus it does not
+// add any cost when serving requests.
+func (g *Group[T]) UseIf(predicate RoutePredicate, middlewares ...MiddlewareFunc[T]) {
+	for _, mw := range middlewares {
+		g.stack = append(g.stack, Middleware[T]{Func: mw, Predicate: predicate})
+	}
+}
+
+// UseNamedIf is like UseIf, but adds a named middleware.
+func (g *Group[T]) UseNamedIf(name string, predicate RoutePredicate, middleware MiddlewareFunc[T]) {
+	g.stack = append(g.stack, Middleware[T]{Name: name, Func: middleware, Predicate: predicate})
+}
+
+// With returns a derived Group which has the same path prefix as g,
+// and a middleware stack which appends the given middlewares to
+// the stack of g.
+// Routes added to the returned Group are wrapped by the middlewares,
+// while g itself is not changed.
+func (g *Group[T]) With(middlewares ...MiddlewareFunc[T]) *Group[T] {
+	return g.derive(toMiddlewares(middlewares))
+}
+
+// WithNamed is like With, but adds a named middleware.
+func (g *Group[T]) WithNamed(name string, middleware MiddlewareFunc[T]) *Group[T] {
+	return g.derive([]Middleware[T]{{Name: name, Func: middleware}})
+}
+
+func (g *Group[T]) derive(middlewares []Middleware[T]) *Group[T] {
+	stack := make([]Middleware[T], 0, len(g.stack)+len(middlewares))
+	stack = append(stack, g.stack...)
+	stack = append(stack, middlewares...)
+	return &Group[T]{
+		path:  g.path,
+		mux:   g.mux,
+		stack: stack,
+		cors:  g.cors,
+
+		version:           g.version,
+		versionDeprecated: g.versionDeprecated,
+
+		rateLimit: g.rateLimit,
+
+		timeout:     g.timeout,
+		maxBodySize: g.maxBodySize,
+	}
 }
 
 // Handle adds routing rules to Group.
@@ -114,19 +221,136 @@ func (g *Group[T]) Use(middlewares ...MiddlewareFunc[T]) {
 //	GET /posts will redirect to /posts/.
 //	GET /posts/ will match normally.
 //	POST /posts will redirect to /posts/, because the GET method used a trailing slash.
-func (g *Group[T]) Handle(method string, path string, handler T) {
+//
+// # Route Options
+//
+// Options can be given to configure the single route, e.g. WithMiddlewares
+// adds middlewares which only wrap this route.
+func (g *Group[T]) Handle(method string, path string, handler T, opts ...RouteOption[T]) {
+	checkMethod(method)
+
 	g.mux.mutex.Lock()
 	defer g.mux.mutex.Unlock()
 
-	if len(g.stack) > 0 {
-		handler = withMiddlewares(handler, g.stack)
+	options := routeOptions[T]{
+		mux:               g.mux,
+		cors:              g.cors,
+		version:           g.version,
+		versionDeprecated: g.versionDeprecated,
+		rateLimit:         g.rateLimit,
+		timeout:           g.timeout,
+		maxBodySize:       g.maxBodySize,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if !options.rateLimitSet {
+		if limit, ok := options.metadata[RateLimitMetadataKey].(*RateLimit); ok {
+			options.rateLimit = limit
+		}
 	}
 
-	g.addFullStackHandler(method, path, handler)
+	if options.localized != nil {
+		if paths, ok := g.mux.localized[options.name]; ok && !equalLocalizedPaths(paths, options.localized) {
+			panic(fmt.Sprintf("treemux: localized route %s is already registered with other paths", options.name))
+		}
+	}
+
+	fullPath := g.path + path
+	route := &Route[T]{
+		RouteInfo: RouteInfo{
+			Method:    method,
+			Path:      fullPath,
+			RouteType: getRouteType(fullPath),
+			Metadata:  options.metadata,
+			CORS:      options.cors,
+			Produces:  options.produces,
+			Consumes:  options.consumes,
+			Matchers:  options.matchers,
+
+			Version:           options.version,
+			VersionDeprecated: options.versionDeprecated,
+			Deprecation:       options.deprecation,
+
+			Variant:  options.variant,
+			Upstream: options.upstream,
+
+			RateLimit: options.rateLimit,
+			Cache:     options.cache,
+
+			Timeout:     options.timeout,
+			MaxBodySize: options.maxBodySize,
+
+			Aliases: aliasPatterns(g.path, options.aliases),
+
+			Name:      options.name,
+			Localized: options.localized,
+		},
+		Handler:   handler,
+		subRouter: options.subRouter,
+		state:     &routeState{},
+
+		passParams: options.passParams || options.cache != nil ||
+			(options.rateLimit != nil && options.rateLimit.KeyParam != ""),
+	}
+
+	stack := make([]Middleware[T], 0, len(g.stack)+len(options.middlewares))
+	for _, mw := range g.stack {
+		if mw.Name != "" && options.excludes[mw.Name] {
+			continue
+		}
+		if mw.Predicate != nil && !mw.Predicate(&route.RouteInfo) {
+			continue
+		}
+		stack = append(stack, mw)
+	}
+	stack = append(stack, options.middlewares...)
+	if options.rateLimit != nil {
+		stack = g.appendHTTPMiddleware(stack, "ratelimit", g.mux.rateLimitMiddleware(fullPath, options.rateLimit))
+	}
+	if options.cache != nil {
+		stack = g.appendHTTPMiddleware(stack, "cache", g.mux.cacheMiddleware(fullPath, &route.RouteInfo, options.cache))
+	}
+	if options.bulkhead != nil || options.breaker != nil {
+		stack = g.appendHTTPMiddleware(stack, "resilience", g.mux.resilienceMiddleware(fullPath, options.bulkhead, options.breaker))
+	}
+	if options.mirror != nil {
+		stack = g.appendHTTPMiddleware(stack, "mirror", options.mirror.middleware(fullPath))
+	}
+	if len(stack) > 0 {
+		route.Middlewares = stack
+		handler = withMiddlewares(handler, stack)
+	}
+
+	var v *variant[T]
+	if options.hasConstraints() {
+		v = newVariant(route, handler, options.split)
+	}
+
+	g.addFullStackHandler(method, path, handler, route, v, nil)
+	for i := range options.aliases {
+		g.addFullStackHandler(method, options.aliases[i].Pattern, handler, route, v, &options.aliases[i])
+	}
+	if options.localized != nil {
+		if g.mux.localized == nil {
+			g.mux.localized = make(map[string]map[string]string)
+		}
+		g.mux.localized[options.name] = options.localized
+	}
 }
 
-func (g *Group[T]) addFullStackHandler(method string, path string, handler T) {
+// appendHTTPMiddleware appends the built-in [http.Handler] middleware
+// mw named name to stack.
+func (g *Group[T]) appendHTTPMiddleware(stack []Middleware[T], name string, mw HTTPHandlerMiddleware) []Middleware[T] {
+	if g.mux.Bridge == nil {
+		panic("treemux: Bridge is not configured")
+	}
+	return append(stack, Middleware[T]{Name: name, Func: g.mux.Bridge.ConvertMiddleware(mw)})
+}
+
+func (g *Group[T]) addFullStackHandler(method string, path string, handler T, route *Route[T], v *variant[T], alias *Alias) {
 	fullPath := g.path + path
+	locales := route.localesOf(fullPath)
 	addSlash := false
 	addOne := func(thePath string) {
 		if g.mux.CaseInsensitive {
@@ -137,8 +361,31 @@ func (g *Group[T]) addFullStackHandler(method string, path string, handler T) {
 		if addSlash {
 			node.addSlash = true
 		}
-		node.setHandler(method, handler, false)
 		node.fullPath = fullPath
+		if alias != nil {
+			if node.leafAliases == nil {
+				node.leafAliases = make(map[string]*routeAlias)
+			}
+			node.leafAliases[method] = newRouteAlias(alias, route.Path, node.leafParamNames)
+		}
+		if locales != nil {
+			if node.leafLocales == nil {
+				node.leafLocales = make(map[string][]string)
+			}
+			node.leafLocales[method] = locales
+		}
+		if v != nil {
+			node.addVariant(method, v)
+		} else if !node.setDefaultHandler(method, handler, route) {
+			node.setHandler(method, handler, false)
+			node.setRoute(method, route)
+		}
+		if route.CORS != nil {
+			node.hasCORS = true
+		}
+		if route.subRouter != nil {
+			node.subRouter = route.subRouter
+		}
 
 		headHandler := node.leafHandlers["HEAD"]
 		if g.mux.HeadCanUseGet && method == "GET" && !g.mux.Bridge.IsHandlerValid(headHandler) {
@@ -172,39 +419,77 @@ func (g *Group[T]) addFullStackHandler(method string, path string, handler T) {
 	addOne(path)
 }
 
-// GET is a shortcut for Handle("GET", path, handler).
-func (g *Group[T]) GET(path string, handler T) {
-	g.Handle("GET", path, handler)
+// GET is a shortcut for Handle("GET", path, handler, opts...).
+func (g *Group[T]) GET(path string, handler T, opts ...RouteOption[T]) {
+	g.Handle("GET", path, handler, opts...)
+}
+
+// POST is a shortcut for Handle("POST", path, handler, opts...).
+func (g *Group[T]) POST(path string, handler T, opts ...RouteOption[T]) {
+	g.Handle("POST", path, handler, opts...)
+}
+
+// PUT is a shortcut for Handle("PUT", path, handler, opts...).
+func (g *Group[T]) PUT(path string, handler T, opts ...RouteOption[T]) {
+	g.Handle("PUT", path, handler, opts...)
 }
 
-// POST is a shortcut for Handle("POST", path, handler).
-func (g *Group[T]) POST(path string, handler T) {
-	g.Handle("POST", path, handler)
+// DELETE is a shortcut for Handle("DELETE", path, handler, opts...).
+func (g *Group[T]) DELETE(path string, handler T, opts ...RouteOption[T]) {
+	g.Handle("DELETE", path, handler, opts...)
 }
 
-// PUT is a shortcut for Handle("PUT", path, handler).
-func (g *Group[T]) PUT(path string, handler T) {
-	g.Handle("PUT", path, handler)
+// PATCH is a shortcut for Handle("PATCH", path, handler, opts...).
+func (g *Group[T]) PATCH(path string, handler T, opts ...RouteOption[T]) {
+	g.Handle("PATCH", path, handler, opts...)
 }
 
-// DELETE is a shortcut for Handle("DELETE", path, handler).
-func (g *Group[T]) DELETE(path string, handler T) {
-	g.Handle("DELETE", path, handler)
+// HEAD is a shortcut for Handle("HEAD", path, handler, opts...).
+func (g *Group[T]) HEAD(path string, handler T, opts ...RouteOption[T]) {
+	g.Handle("HEAD", path, handler, opts...)
 }
 
-// PATCH is a shortcut for Handle("PATCH", path, handler).
-func (g *Group[T]) PATCH(path string, handler T) {
-	g.Handle("PATCH", path, handler)
+// OPTIONS is a shortcut for Handle("OPTIONS", path, handler, opts...).
+func (g *Group[T]) OPTIONS(path string, handler T, opts ...RouteOption[T]) {
+	g.Handle("OPTIONS", path, handler, opts...)
 }
 
-// HEAD is a shortcut for Handle("HEAD", path, handler).
-func (g *Group[T]) HEAD(path string, handler T) {
-	g.Handle("HEAD", path, handler)
+// Any registers a handler which matches requests of any method,
+// including extension methods like PROPFIND, MKCOL and PURGE.
+// It acts as a fallback below the handlers registered explicitly
+// for the same path.
+func (g *Group[T]) Any(path string, handler T, opts ...RouteOption[T]) {
+	g.Handle(MethodAny, path, handler, opts...)
 }
 
-// OPTIONS is a shortcut for Handle("OPTIONS", path, handler).
-func (g *Group[T]) OPTIONS(path string, handler T) {
-	g.Handle("OPTIONS", path, handler)
+// Match registers a handler for each of the given methods.
+func (g *Group[T]) Match(methods []string, path string, handler T, opts ...RouteOption[T]) {
+	for _, method := range methods {
+		g.Handle(method, path, handler, opts...)
+	}
+}
+
+func checkMethod(method string) {
+	if method == MethodAny {
+		return
+	}
+	if method == "" {
+		panic("treemux: method must not be empty")
+	}
+	for i := 0; i < len(method); i++ {
+		if !isTokenChar(method[i]) {
+			panic(fmt.Sprintf("treemux: invalid method %q", method))
+		}
+	}
+}
+
+// isTokenChar reports whether c is a valid character of an HTTP token
+// defined in RFC 7230.
+func isTokenChar(c byte) bool {
+	if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' {
+		return true
+	}
+	return strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0
 }
 
 func checkPath(path string) {
@@ -3,6 +3,7 @@ package treemux
 import (
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"testing"
 )
 
@@ -182,3 +183,68 @@ func TestSetGetAfterHead(t *testing.T) {
 	testMethod("HEAD", "HEAD")
 	testMethod("GET", "GET")
 }
+
+func TestAnyAndMatch(t *testing.T) {
+	var result string
+	makeHandler := func(name string) HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request, params Params) {
+			result = name
+		}
+	}
+
+	router := New[HandlerFunc]()
+	router.Any("/proxy/*path", makeHandler("ANY"))
+	router.GET("/proxy/*path", makeHandler("GET"))
+	router.Match([]string{"PROPFIND", "MKCOL"}, "/dav/*path", makeHandler("DAV"))
+	router.Handle("PURGE", "/cache/:key", makeHandler("PURGE"))
+
+	testMethod := func(method, path, expect string, expectCode int) {
+		result = ""
+		w := httptest.NewRecorder()
+		r, _ := newRequest(method, path, nil)
+		router.ServeHTTP(w, r)
+		if result != expect {
+			t.Errorf("%s %s: expected handler %q, got %q", method, path, expect, result)
+		}
+		if w.Code != expectCode {
+			t.Errorf("%s %s: expected status %d, got %d", method, path, expectCode, w.Code)
+		}
+	}
+
+	testMethod("GET", "/proxy/a/b", "GET", http.StatusOK)
+	testMethod("HEAD", "/proxy/a/b", "GET", http.StatusOK)
+	testMethod("POST", "/proxy/a/b", "ANY", http.StatusOK)
+	testMethod("PROPFIND", "/proxy/a/b", "ANY", http.StatusOK)
+	testMethod("PROPFIND", "/dav/x", "DAV", http.StatusOK)
+	testMethod("MKCOL", "/dav/x", "DAV", http.StatusOK)
+	testMethod("PURGE", "/cache/abc", "PURGE", http.StatusOK)
+
+	w := httptest.NewRecorder()
+	r, _ := newRequest("DELETE", "/dav/x", nil)
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected status 405, got %d", w.Code)
+	}
+	if allow := w.Header()["Allow"]; !reflect.DeepEqual(allow, []string{"MKCOL", "PROPFIND"}) {
+		t.Errorf("unexpected Allow header %v", allow)
+	}
+
+	found := false
+	for _, route := range router.Routes() {
+		if route.Method == MethodAny && route.Path == "/proxy/*path" {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("route registered by Any is not found")
+	}
+}
+
+func TestInvalidMethod(t *testing.T) {
+	defer func() {
+		if err := recover(); err == nil {
+			t.Error("Invalid method should have caused a panic")
+		}
+	}()
+	New[HandlerFunc]().Handle("BAD METHOD", "/foo", simpleHandler)
+}
@@ -0,0 +1,78 @@
+package treemux
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// headResponseWriter wraps a http.ResponseWriter for a HEAD request
+// served by the GET handler. It discards the response body, while
+// preserving the headers and computing the `Content-Length` header
+// from the discarded bytes.
+type headResponseWriter struct {
+	http.ResponseWriter
+	status  int
+	written int64
+	flushed bool
+}
+
+func (w *headResponseWriter) WriteHeader(code int) {
+	if code >= 100 && code < 200 {
+		// Informational headers are sent immediately.
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if w.status == 0 {
+		w.status = code
+	}
+}
+
+func (w *headResponseWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.written += int64(len(p))
+	return len(p), nil
+}
+
+func (w *headResponseWriter) WriteString(s string) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.written += int64(len(s))
+	return len(s), nil
+}
+
+// Flush sends the headers without computing the `Content-Length`
+// header, since the length of the GET response is not known yet.
+func (w *headResponseWriter) Flush() {
+	w.writeHeader(false)
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the underlying http.ResponseWriter,
+// it works with [http.ResponseController].
+func (w *headResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *headResponseWriter) writeHeader(computeLength bool) {
+	if w.flushed {
+		return
+	}
+	w.flushed = true
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	header := w.ResponseWriter.Header()
+	if computeLength && w.written > 0 && header.Get("Content-Length") == "" && header.Get("Transfer-Encoding") == "" {
+		header.Set("Content-Length", strconv.FormatInt(w.written, 10))
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+}
+
+func (w *headResponseWriter) finish() {
+	w.writeHeader(true)
+}
@@ -0,0 +1,86 @@
+package treemux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestImplicitHeadBodySuppression(t *testing.T) {
+	var implicitHead bool
+	router := New[HandlerFunc]()
+	router.UseContextData = true
+	router.GET("/hello", func(w http.ResponseWriter, r *http.Request, _ Params) {
+		implicitHead = GetRouteData(r).IsImplicitHead()
+		w.Header().Set("X-Custom", "value")
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("hello, "))
+		w.Write([]byte("world"))
+	})
+	router.HEAD("/explicit", func(w http.ResponseWriter, r *http.Request, _ Params) {
+		implicitHead = GetRouteData(r).IsImplicitHead()
+		w.Write([]byte("explicit"))
+	})
+	router.GET("/explicit", simpleHandler)
+
+	w := httptest.NewRecorder()
+	r, _ := newRequest("HEAD", "/hello", nil)
+	router.ServeHTTP(w, r)
+	if !implicitHead {
+		t.Error("expected implicit HEAD in context data")
+	}
+	if w.Code != http.StatusAccepted {
+		t.Errorf("expected status 202, got %d", w.Code)
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("expected empty body, got %q", w.Body.String())
+	}
+	if got := w.Header().Get("Content-Length"); got != "12" {
+		t.Errorf("expected Content-Length 12, got %q", got)
+	}
+	if got := w.Header().Get("X-Custom"); got != "value" {
+		t.Errorf("expected header X-Custom, got %q", got)
+	}
+
+	w = httptest.NewRecorder()
+	r, _ = newRequest("GET", "/hello", nil)
+	router.ServeHTTP(w, r)
+	if implicitHead {
+		t.Error("GET request should not be an implicit HEAD")
+	}
+	if w.Body.String() != "hello, world" {
+		t.Errorf("unexpected body %q", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	r, _ = newRequest("HEAD", "/explicit", nil)
+	router.ServeHTTP(w, r)
+	if implicitHead {
+		t.Error("explicit HEAD route should not be an implicit HEAD")
+	}
+	if w.Body.String() != "explicit" {
+		t.Errorf("explicit HEAD handler body should not be suppressed, got %q", w.Body.String())
+	}
+}
+
+func TestImplicitHeadFlush(t *testing.T) {
+	router := New[HandlerFunc]()
+	router.GET("/stream", func(w http.ResponseWriter, r *http.Request, _ Params) {
+		w.Write([]byte("hello"))
+		w.(http.Flusher).Flush()
+		w.Write(make([]byte, 100))
+	})
+
+	w := httptest.NewRecorder()
+	r, _ := newRequest("HEAD", "/stream", nil)
+	router.ServeHTTP(w, r)
+	if !w.Flushed {
+		t.Error("expected the response to be flushed")
+	}
+	if got := w.Header().Get("Content-Length"); got != "" {
+		t.Errorf("expected no Content-Length after flushing, got %q", got)
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("expected empty body, got %q", w.Body.String())
+	}
+}
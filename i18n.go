@@ -0,0 +1,272 @@
+package treemux
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// LocaleParam is the name of the path parameter which reports the
+// locale of a localized route, see Group.HandleLocalized.
+const LocaleParam = "lang"
+
+// LocaleExtractor extracts the locale from a request.
+// It returns an empty string if the request does not specify a locale.
+type LocaleExtractor func(r *http.Request) string
+
+// LocaleFromPathPrefix returns a LocaleExtractor which extracts the
+// locale from the first segment of the request path, e.g. "de" from
+// "/de/ueber-uns", if it is one of the supported locales.
+func LocaleFromPathPrefix(supported ...string) LocaleExtractor {
+	return func(r *http.Request) string {
+		segment := strings.TrimPrefix(r.URL.Path, "/")
+		if i := strings.IndexByte(segment, '/'); i >= 0 {
+			segment = segment[:i]
+		}
+		return matchLocale(supported, segment)
+	}
+}
+
+// LocaleFromHost returns a LocaleExtractor which extracts the locale
+// from the request host by hosts, which maps host names to locales.
+func LocaleFromHost(hosts map[string]string) LocaleExtractor {
+	return func(r *http.Request) string {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		return hosts[strings.ToLower(host)]
+	}
+}
+
+// LocaleFromAcceptLanguage returns a LocaleExtractor which selects the
+// supported locale preferred by the `Accept-Language` header.
+// A language range matches a locale if they are equal, else its
+// language matches the locale of the same language, e.g. "de-DE"
+// matches "de", and "de" matches "de-AT" if "de" is not supported.
+func LocaleFromAcceptLanguage(supported ...string) LocaleExtractor {
+	return func(r *http.Request) string {
+		best, bestQ := "", 0.0
+		for _, part := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+			params := strings.Split(part, ";")
+			tag := strings.TrimSpace(params[0])
+			if tag == "" || tag == "*" {
+				continue
+			}
+			q := 1.0
+			for _, param := range params[1:] {
+				key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+				if strings.TrimSpace(key) == "q" {
+					if f, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+						q = f
+					}
+				}
+			}
+			if q <= bestQ {
+				continue
+			}
+			locale := matchLocale(supported, tag)
+			if locale == "" {
+				tag, _, _ = strings.Cut(tag, "-")
+				if locale = matchLocale(supported, tag); locale == "" {
+					for _, s := range supported {
+						if lang, _, _ := strings.Cut(s, "-"); strings.EqualFold(lang, tag) {
+							locale = s
+							break
+						}
+					}
+				}
+			}
+			if locale != "" {
+				best, bestQ = locale, q
+			}
+		}
+		return best
+	}
+}
+
+// ChainLocaleExtractors returns a LocaleExtractor which calls the
+// extractors in order and returns the first non-empty locale.
+func ChainLocaleExtractors(extractors ...LocaleExtractor) LocaleExtractor {
+	return func(r *http.Request) string {
+		for _, extract := range extractors {
+			if locale := extract(r); locale != "" {
+				return locale
+			}
+		}
+		return ""
+	}
+}
+
+func matchLocale(supported []string, locale string) string {
+	for _, s := range supported {
+		if strings.EqualFold(s, locale) {
+			return s
+		}
+	}
+	return ""
+}
+
+// HandleLocalized registers a localized route named name, which has a
+// pattern per locale, e.g.
+//
+//	router.HandleLocalized("GET", "about", map[string]string{
+//		"en": "/en/about",
+//		"de": "/de/ueber-uns",
+//		"fr": "/fr/a-propos",
+//	}, aboutHandler)
+//
+// The patterns are relative to the group, they must have the same
+// parameters. The pattern of Router.DefaultLocale, or else of the first
+// locale in alphabetical order, is the canonical pattern reported as
+// RoutePath, the other patterns are registered as aliases, see
+// WithAlias. Router.DefaultLocale is read at registration, thus it
+// must be set before HandleLocalized is called.
+//
+// A name can be registered for several methods, with the same paths,
+// registering it with other paths panics.
+//
+// The locale of a request is the locale of the matched pattern, it is
+// reported by LookupResult.Locale, RouteData.Locale and the path
+// parameter LocaleParam. If several locales share a pattern, the locale
+// is chosen by Router.LocaleExtractor among them.
+// Router.URL and Router.URLFor generate the URLs of localized routes.
+func (g *Group[T]) HandleLocalized(method, name string, paths map[string]string, handler T, opts ...RouteOption[T]) {
+	if len(paths) == 0 {
+		panic("treemux: localized route " + name + " has no path")
+	}
+	locales := make([]string, 0, len(paths))
+	for locale := range paths {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	canonical := paths[locales[0]]
+	if p, ok := paths[g.mux.DefaultLocale]; ok {
+		canonical = p
+	}
+
+	localized := make(map[string]string, len(paths))
+	var aliases []Alias
+	for _, locale := range locales {
+		p := paths[locale]
+		localized[locale] = g.path + p
+		if p != canonical && !containsAlias(aliases, p) {
+			aliases = append(aliases, Alias{Pattern: p})
+		}
+	}
+	opts = append(opts, func(opts *routeOptions[T]) {
+		opts.name = name
+		opts.localized = localized
+		opts.aliases = append(opts.aliases, aliases...)
+	})
+	g.Handle(method, canonical, handler, opts...)
+}
+
+func equalLocalizedPaths(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for locale, p := range a {
+		if q, ok := b[locale]; !ok || q != p {
+			return false
+		}
+	}
+	return true
+}
+
+func containsAlias(aliases []Alias, pattern string) bool {
+	for _, alias := range aliases {
+		if alias.Pattern == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// localesOf returns the sorted locales of the route which have the
+// pattern fullPath.
+func (r *Route[T]) localesOf(fullPath string) []string {
+	var locales []string
+	for locale, p := range r.Localized {
+		if p == fullPath {
+			locales = append(locales, locale)
+		}
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// selectLocale returns the locale of a request matched to a node with
+// the given locales.
+func (t *Router[T]) selectLocale(r *http.Request, locales []string) string {
+	if len(locales) == 1 {
+		return locales[0]
+	}
+	if t.LocaleExtractor != nil && r != nil {
+		if locale := matchLocale(locales, t.LocaleExtractor(r)); locale != "" {
+			return locale
+		}
+	}
+	if locale := matchLocale(locales, t.DefaultLocale); locale != "" {
+		return locale
+	}
+	return locales[0]
+}
+
+// URL generates the path of the localized route name for locale,
+// params are pairs of parameter names and values, e.g.
+//
+//	router.URL("user", "de", "id", "42") // "/de/benutzer/42"
+//
+// If the route has no pattern for locale, the pattern of
+// Router.DefaultLocale is used.
+func (t *Router[T]) URL(name, locale string, params ...string) (string, error) {
+	if len(params)%2 != 0 {
+		return "", fmt.Errorf("treemux: odd number of params for route %q", name)
+	}
+	t.mutex.RLock()
+	paths := t.localized[name]
+	t.mutex.RUnlock()
+	if paths == nil {
+		return "", fmt.Errorf("treemux: localized route %q is not registered", name)
+	}
+	pattern, ok := paths[locale]
+	if !ok {
+		if pattern, ok = paths[t.DefaultLocale]; !ok {
+			return "", fmt.Errorf("treemux: localized route %q has no path for locale %q", name, locale)
+		}
+	}
+
+	u, err := buildPath(pattern, func(name string) (string, bool) {
+		return lookupPair(params, name)
+	})
+	if err == errRegexpSegment {
+		return "", fmt.Errorf("treemux: can not generate URL of regexp route %q", name)
+	}
+	if err != nil {
+		return "", fmt.Errorf("treemux: %v for route %q", err, name)
+	}
+	return u, nil
+}
+
+// URLFor is like URL, but uses the locale of the request, see
+// RouteData.Locale.
+func (t *Router[T]) URLFor(r *http.Request, name string, params ...string) (string, error) {
+	locale := GetRouteData(r).Locale()
+	if locale == "" {
+		locale = t.DefaultLocale
+	}
+	return t.URL(name, locale, params...)
+}
+
+func lookupPair(pairs []string, key string) (string, bool) {
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if pairs[i] == key {
+			return pairs[i+1], true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,150 @@
+package treemux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLocalizedRoutes(t *testing.T) {
+	router := New[HandlerFunc]()
+	router.UseContextData = true
+	router.DefaultLocale = "en"
+	router.LocaleExtractor = LocaleFromAcceptLanguage("en", "de-CH", "de")
+
+	var gotRoute, gotLocale, gotURL string
+	var gotParams Params
+	handler := func(w http.ResponseWriter, r *http.Request, params Params) {
+		rd := GetRouteData(r)
+		gotRoute, gotLocale, gotParams = rd.Route(), rd.Locale(), params
+		gotURL, _ = router.URLFor(r, "user", "id", params.Get("id"))
+	}
+	router.HandleLocalized("GET", "about", map[string]string{
+		"en": "/en/about",
+		"de": "/de/ueber-uns",
+		"fr": "/fr/a-propos",
+	}, handler)
+	router.NewGroup("/site").HandleLocalized("GET", "user", map[string]string{
+		"en":    "/en/users/:id",
+		"de":    "/de/benutzer/:id",
+		"de-CH": "/de/benutzer/:id",
+	}, handler)
+	router.GET("/health", handler)
+
+	serve := func(path, acceptLanguage string) *httptest.ResponseRecorder {
+		gotRoute, gotLocale, gotURL, gotParams = "", "", "", Params{}
+		w := httptest.NewRecorder()
+		r, _ := newRequest("GET", path, nil)
+		if acceptLanguage != "" {
+			r.Header.Set("Accept-Language", acceptLanguage)
+		}
+		router.ServeHTTP(w, r)
+		return w
+	}
+
+	for path, locale := range map[string]string{"/en/about": "en", "/de/ueber-uns": "de", "/fr/a-propos": "fr"} {
+		if w := serve(path, ""); w.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d", path, w.Code)
+		}
+		if gotRoute != "/en/about" || gotLocale != locale || gotParams.Get(LocaleParam) != locale {
+			t.Errorf("%s: expected route /en/about in %s, got %s in %s %v", path, locale, gotRoute, gotLocale, gotParams)
+		}
+	}
+
+	serve("/site/de/benutzer/42", "de-CH, de;q=0.9")
+	if gotLocale != "de-CH" || gotParams.Get("id") != "42" || gotURL != "/site/de/benutzer/42" {
+		t.Errorf("expected de-CH, got %s %v %s", gotLocale, gotParams, gotURL)
+	}
+	serve("/site/de/benutzer/42", "fr")
+	if gotLocale != "de" {
+		t.Errorf("expected the first locale of a shared pattern, got %s", gotLocale)
+	}
+	serve("/site/en/users/7", "de")
+	if gotLocale != "en" || gotURL != "/site/en/users/7" {
+		t.Errorf("expected en, got %s %s", gotLocale, gotURL)
+	}
+
+	serve("/health", "de-DE, fr;q=0.5")
+	if gotLocale != "de" || gotParams.Get(LocaleParam) != "" {
+		t.Errorf("expected the extracted locale without param, got %s %v", gotLocale, gotParams)
+	}
+	serve("/health", "")
+	if gotLocale != "en" {
+		t.Errorf("expected the default locale, got %s", gotLocale)
+	}
+
+	if u, err := router.URL("user", "de", "id", "a b"); err != nil || u != "/site/de/benutzer/a%20b" {
+		t.Errorf("unexpected URL %q %v", u, err)
+	}
+	if u, err := router.URL("about", "it"); err != nil || u != "/en/about" {
+		t.Errorf("expected the default locale URL, got %q %v", u, err)
+	}
+	if _, err := router.URL("user", "en"); err == nil {
+		t.Error("expected an error for the missing param")
+	}
+	if _, err := router.URL("unknown", "en"); err == nil {
+		t.Error("expected an error for the unknown route")
+	}
+
+	routes := router.Routes()
+	for _, route := range routes {
+		if route.Name == "about" && (route.Path != "/en/about" || len(route.Aliases) != 2 || route.Localized["fr"] != "/fr/a-propos") {
+			t.Errorf("unexpected route info %+v", route.RouteInfo)
+		}
+	}
+}
+
+func TestLocaleExtractors(t *testing.T) {
+	r, _ := newRequest("GET", "/de/x", nil)
+	r.Host = "example.fr:8080"
+	if got := LocaleFromPathPrefix("en", "de")(r); got != "de" {
+		t.Errorf("expected de from the path, got %q", got)
+	}
+	if got := LocaleFromHost(map[string]string{"example.fr": "fr"})(r); got != "fr" {
+		t.Errorf("expected fr from the host, got %q", got)
+	}
+	r.Header.Set("Accept-Language", "it;q=0.9, en;q=0.3, *;q=0.1")
+	if got := LocaleFromAcceptLanguage("en", "it")(r); got != "it" {
+		t.Errorf("expected it from Accept-Language, got %q", got)
+	}
+	chain := ChainLocaleExtractors(LocaleFromPathPrefix("en"), LocaleFromAcceptLanguage("en"))
+	if got := chain(r); got != "en" {
+		t.Errorf("expected en from the chain, got %q", got)
+	}
+}
+
+func TestLocalizedRouteSharedNode(t *testing.T) {
+	router := New[HandlerFunc]()
+	router.UseContextData = true
+	router.DefaultLocale = "en"
+
+	var gotLocale string
+	var gotParams Params
+	handler := func(w http.ResponseWriter, r *http.Request, params Params) {
+		gotLocale, gotParams = GetRouteData(r).Locale(), params
+	}
+	router.HandleLocalized("GET", "about", map[string]string{"en": "/en/about", "de": "/de/about"}, handler)
+	router.HandleLocalized("HEAD", "about", map[string]string{"en": "/en/about", "de": "/de/about"}, handler)
+	router.POST("/de/about", handler)
+
+	serve := func(method, path string) {
+		gotLocale, gotParams = "", Params{}
+		r, _ := newRequest(method, path, nil)
+		router.ServeHTTP(httptest.NewRecorder(), r)
+	}
+	serve("GET", "/de/about")
+	if gotLocale != "de" || gotParams.Get(LocaleParam) != "de" {
+		t.Errorf("expected the de locale, got %q %v", gotLocale, gotParams)
+	}
+	serve("POST", "/de/about")
+	if gotLocale != "en" || gotParams.Get(LocaleParam) != "" {
+		t.Errorf("expected no localized param for a non-localized route, got %q %v", gotLocale, gotParams)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic for a duplicate name with other paths")
+		}
+	}()
+	router.HandleLocalized("PUT", "about", map[string]string{"en": "/en/about-us"}, handler)
+}
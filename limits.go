@@ -0,0 +1,150 @@
+package treemux
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// UseTimeout sets the timeout of the routes added to the group after
+// the call, zero means no timeout. WithTimeout overrides it for a
+// single route.
+//
+// The request's context has the deadline of the timeout. If the handler
+// does not finish in time, the client receives the response of
+// Router.ServiceUnavailableHandler, and the output of the handler is
+// discarded. The response of a route with a timeout is buffered, thus
+// the handler can not stream the response or hijack the connection.
+//
+// Bridges which serve lookup results themselves, like the gin and
+// hertz bridges, only set the deadline of the request's context.
+func (g *Group[T]) UseTimeout(timeout time.Duration) {
+	g.timeout = timeout
+}
+
+// UseMaxBodySize sets the maximum size of request bodies of the routes
+// added to the group after the call, zero means no limit.
+// WithMaxBodySize overrides it for a single route.
+//
+// Requests with a larger `Content-Length` are rejected with 413
+// Request Entity Too Large, and reading a larger body returns an error,
+// see [http.MaxBytesReader].
+func (g *Group[T]) UseMaxBodySize(n int64) {
+	g.maxBodySize = n
+}
+
+// WithTimeout returns a RouteOption which sets the timeout of a single
+// route, see Group.UseTimeout.
+func WithTimeout[T HandlerConstraint](timeout time.Duration) RouteOption[T] {
+	return func(opts *routeOptions[T]) {
+		opts.timeout = timeout
+	}
+}
+
+// WithMaxBodySize returns a RouteOption which sets the maximum size of
+// request bodies of a single route, see Group.UseMaxBodySize.
+func WithMaxBodySize[T HandlerConstraint](n int64) RouteOption[T] {
+	return func(opts *routeOptions[T]) {
+		opts.maxBodySize = n
+	}
+}
+
+// limitRequestBody limits the request body to n bytes, it responds 413
+// and returns false if the request's Content-Length is larger than n.
+func limitRequestBody(w http.ResponseWriter, r *http.Request, n int64) bool {
+	if r.ContentLength > n {
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		return false
+	}
+	if r.Body != nil && r.Body != http.NoBody {
+		r.Body = http.MaxBytesReader(w, r.Body, n)
+	}
+	return true
+}
+
+// serveWithTimeout serves the request by handler with a deadline,
+// if the handler does not finish in time, the request is served by
+// ServiceUnavailableHandler. If the request is canceled before, the
+// response is discarded.
+func (t *Router[T]) serveWithTimeout(w http.ResponseWriter, r *http.Request, timeout time.Duration, handler http.HandlerFunc) {
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+	r = r.WithContext(ctx)
+
+	tw := &timeoutWriter{header: make(http.Header)}
+	done := make(chan struct{})
+	panicChan := make(chan interface{}, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				panicChan <- p
+			}
+		}()
+		handler(tw, r)
+		close(done)
+	}()
+
+	select {
+	case p := <-panicChan:
+		// Propagate the panic to Router.PanicHandler.
+		panic(p)
+	case <-done:
+		tw.mu.Lock()
+		defer tw.mu.Unlock()
+		// Keep the headers set before the handler, e.g. by CORS.
+		dst := w.Header()
+		for k, v := range tw.header {
+			dst[k] = append(dst[k], v...)
+		}
+		if tw.statusCode == 0 {
+			tw.statusCode = http.StatusOK
+		}
+		w.WriteHeader(tw.statusCode)
+		w.Write(tw.body.Bytes())
+	case <-ctx.Done():
+		tw.mu.Lock()
+		defer tw.mu.Unlock()
+		tw.timedOut = true
+		// A request canceled by the client gets no response.
+		if ctx.Err() == context.DeadlineExceeded {
+			t.ServiceUnavailableHandler(w, r)
+		}
+	}
+}
+
+// timeoutWriter buffers the response of a handler served with a timeout,
+// writes after the timeout fail with [http.ErrHandlerTimeout].
+type timeoutWriter struct {
+	mu         sync.Mutex
+	header     http.Header
+	statusCode int
+	body       bytes.Buffer
+	timedOut   bool
+}
+
+func (w *timeoutWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *timeoutWriter) WriteHeader(statusCode int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timedOut || w.statusCode != 0 {
+		return
+	}
+	w.statusCode = statusCode
+}
+
+func (w *timeoutWriter) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if w.statusCode == 0 {
+		w.statusCode = http.StatusOK
+	}
+	return w.body.Write(b)
+}
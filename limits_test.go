@@ -0,0 +1,140 @@
+package treemux
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTimeout(t *testing.T) {
+	router := New[HandlerFunc]()
+	router.PanicHandler = SimplePanicHandler
+	api := router.NewGroup("/api")
+	api.UseTimeout(20 * time.Millisecond)
+	api.GET("/slow", func(w http.ResponseWriter, r *http.Request, params Params) {
+		<-r.Context().Done()
+		w.Write([]byte("late"))
+	})
+	api.GET("/fast", func(w http.ResponseWriter, r *http.Request, params Params) {
+		if _, ok := r.Context().Deadline(); !ok {
+			t.Error("expected the request context to have a deadline")
+		}
+		w.Header().Set("X-Test", "1")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("ok"))
+	})
+	api.GET("/panic", func(w http.ResponseWriter, r *http.Request, params Params) {
+		panic("boom")
+	})
+	api.GET("/upload", func(w http.ResponseWriter, r *http.Request, params Params) {
+		if _, ok := r.Context().Deadline(); ok {
+			t.Error("expected no deadline")
+		}
+	}, WithTimeout[HandlerFunc](0))
+
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r, _ := newRequest(method, path, nil)
+		router.ServeHTTP(w, r)
+		return w
+	}
+
+	if w := serve("GET", "/api/slow"); w.Code != http.StatusServiceUnavailable || w.Body.Len() != 0 {
+		t.Errorf("expected 503 on timeout, got %d %q", w.Code, w.Body.String())
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	canceled := httptest.NewRecorder()
+	r, _ := newRequest("GET", "/api/slow", nil)
+	router.ServeHTTP(canceled, r.WithContext(ctx))
+	if canceled.Code == http.StatusServiceUnavailable || canceled.Body.Len() != 0 {
+		t.Errorf("expected no response to a canceled request, got %d %q", canceled.Code, canceled.Body.String())
+	}
+	w := serve("GET", "/api/fast")
+	if w.Code != http.StatusCreated || w.Body.String() != "ok" || w.Header().Get("X-Test") != "1" {
+		t.Errorf("unexpected response %d %q %v", w.Code, w.Body.String(), w.Header())
+	}
+	w = serve("HEAD", "/api/fast")
+	if w.Code != http.StatusCreated || w.Body.Len() != 0 || w.Header().Get("Content-Length") != "2" {
+		t.Errorf("unexpected HEAD response %d %q %v", w.Code, w.Body.String(), w.Header())
+	}
+	if w := serve("GET", "/api/panic"); w.Code != http.StatusInternalServerError {
+		t.Errorf("expected the panic to be handled, got %d", w.Code)
+	}
+	if w := serve("GET", "/api/upload"); w.Code != http.StatusOK {
+		t.Errorf("expected 200, got %d", w.Code)
+	}
+
+	lr, _ := router.LookupByPath("GET", "/api/slow", "/api/slow")
+	if lr.Route().Timeout != 20*time.Millisecond {
+		t.Errorf("expected the timeout in route info, got %v", lr.Route().Timeout)
+	}
+	if !strings.Contains(router.Dump(), "GET timeout 20ms max body 0") {
+		t.Errorf("expected the timeout in dump, got\n%s", router.Dump())
+	}
+}
+
+func TestTimeoutKeepsHeaders(t *testing.T) {
+	router := New[HandlerFunc]()
+	router.GET("/items", func(w http.ResponseWriter, r *http.Request, params Params) {
+		w.Header().Add("Vary", "Accept-Encoding")
+	}, WithTimeout[HandlerFunc](time.Second), WithProduces[HandlerFunc]("application/json"),
+		WithDeprecation[HandlerFunc](&Deprecation{Successor: "/v2/items"}))
+
+	w := httptest.NewRecorder()
+	r, _ := newRequest("GET", "/items", nil)
+	router.ServeHTTP(w, r)
+	if got := w.Header().Values("Vary"); strings.Join(got, ",") != "Accept,Accept-Encoding" {
+		t.Errorf("unexpected Vary header %v", got)
+	}
+	if w.Header().Get("Deprecation") != "true" || w.Header().Get("Link") == "" {
+		t.Errorf("expected the deprecation headers, got %v", w.Header())
+	}
+}
+
+func TestMaxBodySize(t *testing.T) {
+	router := New[HandlerFunc]()
+	router.UseMaxBodySize(4)
+	handler := func(w http.ResponseWriter, r *http.Request, params Params) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+		w.Write(body)
+	}
+	router.POST("/small", handler)
+	router.POST("/upload", handler, WithMaxBodySize[HandlerFunc](100))
+
+	serve := func(path, body string, chunked bool) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r, _ := newRequest("POST", path, strings.NewReader(body))
+		if chunked {
+			r.ContentLength = -1
+		}
+		router.ServeHTTP(w, r)
+		return w
+	}
+
+	if w := serve("/small", "1234", false); w.Code != http.StatusOK || w.Body.String() != "1234" {
+		t.Errorf("expected 200, got %d %q", w.Code, w.Body.String())
+	}
+	if w := serve("/small", "12345", false); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413 by Content-Length, got %d", w.Code)
+	}
+	if w := serve("/small", "12345", true); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected reading the body to fail, got %d", w.Code)
+	}
+	if w := serve("/upload", "12345", false); w.Code != http.StatusOK {
+		t.Errorf("expected 200 for the route limit, got %d", w.Code)
+	}
+
+	lr, _ := router.LookupByPath("POST", "/upload", "/upload")
+	if lr.Route().MaxBodySize != 100 {
+		t.Errorf("expected the limit in route info, got %d", lr.Route().MaxBodySize)
+	}
+}
@@ -0,0 +1,222 @@
+package treemux
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Maintenance describes why and how a disabled route responds, see
+// Router.DisableRoutes.
+type Maintenance struct {
+	// Reason is a description of the maintenance, it is only reported
+	// by Router.DisabledRoutes.
+	Reason string
+
+	// RetryAfter, if positive, is sent in the `Retry-After` header.
+	RetryAfter time.Duration
+
+	// Handler, if not nil, serves the requests of the disabled routes,
+	// else they are served by Router.ServiceUnavailableHandler.
+	// It is not used by bridges which serve lookup results themselves,
+	// see Router.RouteHeaders.
+	Handler http.Handler
+}
+
+// DisabledRoute is a route disabled by Router.DisableRoutes.
+type DisabledRoute struct {
+	Method      string
+	Path        string
+	Maintenance *Maintenance
+}
+
+// routeState is the state of a route which can be changed at runtime,
+// it is shared by the copies of the route.
+type routeState struct {
+	deprecatedCalls uint64 // accessed atomically, keep it 64-bit aligned
+
+	maintenance atomic.Value // *Maintenance
+}
+
+// Maintenance returns the maintenance of the route if it is disabled,
+// else it returns nil.
+func (r *Route[T]) Maintenance() *Maintenance {
+	if r == nil || r.state == nil {
+		return nil
+	}
+	m, _ := r.state.maintenance.Load().(*Maintenance)
+	return m
+}
+
+// DisableRoutes disables the routes which match predicate at runtime,
+// requests of the disabled routes are answered according to m, which
+// may be nil. It returns the number of routes disabled.
+//
+// Routes can be selected by pattern, group prefix or metadata tag with
+// the predicates MatchPattern, MatchMethods, MatchMetadata etc, e.g.
+//
+//	router.DisableRoutes(And(MatchMethods("POST"), MatchPattern("/orders")), nil)
+//	router.DisableRoutes(MatchMetadata("feature", "checkout"), m)
+//
+// The routing tree is not changed, the lookup checks an atomic flag of
+// the route, thus it stays lock-free. Routes of mounted routers are
+// matched with their full patterns.
+func (t *Router[T]) DisableRoutes(predicate RoutePredicate, m *Maintenance) int {
+	if m == nil {
+		m = &Maintenance{}
+	}
+	return t.setMaintenance("", predicate, m)
+}
+
+// EnableRoutes enables the disabled routes which match predicate,
+// it returns the number of routes which were disabled.
+func (t *Router[T]) EnableRoutes(predicate RoutePredicate) int {
+	return t.setMaintenance("", predicate, nil)
+}
+
+// DisabledRoutes returns the disabled routes, sorted by path and method.
+func (t *Router[T]) DisabledRoutes() []DisabledRoute {
+	var out []DisabledRoute
+	for _, route := range t.Routes() {
+		if m := route.Maintenance(); m != nil {
+			out = append(out, DisabledRoute{Method: route.Method, Path: route.Path, Maintenance: m})
+		}
+	}
+	return out
+}
+
+// DisabledRoutesHandler returns an [http.Handler] which serves the
+// disabled routes as a JSON array, it can be served on an admin
+// endpoint, e.g.
+//
+//	adminMux.Handle("/disabled-routes", router.DisabledRoutesHandler())
+//
+// Each element has the "method", "path", "reason" and "retry_after"
+// fields, retry_after is in seconds.
+func (t *Router[T]) DisabledRoutesHandler() http.Handler {
+	type disabledRoute struct {
+		Method     string `json:"method"`
+		Path       string `json:"path"`
+		Reason     string `json:"reason,omitempty"`
+		RetryAfter int64  `json:"retry_after,omitempty"`
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		out := make([]disabledRoute, 0)
+		for _, route := range t.DisabledRoutes() {
+			out = append(out, disabledRoute{
+				Method:     route.Method,
+				Path:       route.Path,
+				Reason:     route.Maintenance.Reason,
+				RetryAfter: int64(route.Maintenance.RetryAfter / time.Second),
+			})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(out) //nolint:errcheck
+	})
+}
+
+// Disable disables the routes registered under the group's path prefix,
+// routes added after the call are not affected. It returns the number
+// of routes disabled. See Router.DisableRoutes.
+func (g *Group[T]) Disable(m *Maintenance) int {
+	return g.mux.DisableRoutes(g.matchPrefix, m)
+}
+
+// Enable enables the routes of the group, see Group.Disable.
+func (g *Group[T]) Enable() int {
+	return g.mux.EnableRoutes(g.matchPrefix)
+}
+
+func (g *Group[T]) matchPrefix(info *RouteInfo) bool {
+	return info.Path == g.path || strings.HasPrefix(info.Path, g.path+"/")
+}
+
+func (t *Router[T]) setMaintenance(prefix string, predicate RoutePredicate, m *Maintenance) int {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	count := 0
+	seen := make(map[*Route[T]]bool)
+	mounted := make(map[*subRouter[T]]bool)
+	apply := func(route *Route[T]) {
+		if seen[route] || route.state == nil {
+			return
+		}
+		seen[route] = true
+		info := route.RouteInfo
+		info.Path = prefix + info.Path
+		if !predicate(&info) {
+			return
+		}
+		if m != nil || route.Maintenance() != nil {
+			count++
+		}
+		route.state.maintenance.Store(m)
+	}
+	t.root.walk(func(n *node[T]) {
+		if sub := n.subRouter; sub != nil {
+			if !mounted[sub] {
+				mounted[sub] = true
+				count += sub.router.setMaintenance(prefix+sub.prefix, predicate, m)
+			}
+			return
+		}
+		for _, route := range n.leafRoutes {
+			apply(route)
+		}
+		for _, set := range n.leafVariants {
+			for _, v := range set.variants {
+				apply(v.route)
+			}
+		}
+	})
+	return count
+}
+
+// RouteHeaders computes the response headers of the maintenance and
+// deprecation of the matched route, it allows frameworks other than
+// net/http to apply them, see Router.DisableRoutes and WithDeprecation.
+// It counts the call of a deprecated route, thus it should be called
+// once per request.
+//
+// If status is not zero, the request is answered by the router, the
+// caller should write the headers with status and not call the handler.
+// status is 503 Service Unavailable for a disabled route, and 410 Gone
+// for a deprecated route after its sunset. Maintenance.Handler is not
+// called. Else if the returned header is not nil, the caller should add
+// them to the response before calling the handler.
+func (t *Router[T]) RouteHeaders(lr LookupResult[T]) (header http.Header, status int) {
+	route := lr.route
+	if route == nil {
+		return nil, 0
+	}
+	if m := route.Maintenance(); m != nil {
+		header = make(http.Header)
+		if m.RetryAfter > 0 {
+			header.Set("Retry-After", strconv.Itoa(ceilSeconds(m.RetryAfter)))
+		}
+		return header, http.StatusServiceUnavailable
+	}
+	if route.Deprecation != nil || route.VersionDeprecated {
+		header = make(http.Header)
+		if t.setDeprecationHeaders(header, lr) {
+			return header, http.StatusGone
+		}
+	}
+	return header, 0
+}
+
+// serveMaintenance serves a request of a disabled route.
+func (t *Router[T]) serveMaintenance(w http.ResponseWriter, r *http.Request, m *Maintenance) {
+	if m.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(m.RetryAfter)))
+	}
+	if m.Handler != nil {
+		m.Handler.ServeHTTP(w, r)
+		return
+	}
+	t.ServiceUnavailableHandler(w, r)
+}
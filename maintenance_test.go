@@ -0,0 +1,178 @@
+package treemux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMaintenance(t *testing.T) {
+	router := New[HandlerFunc]()
+	handler := func(w http.ResponseWriter, r *http.Request, params Params) {}
+	router.GET("/orders", handler)
+	router.POST("/orders", handler)
+	admin := router.NewGroup("/admin")
+	admin.GET("/users/:id", handler)
+	admin.GET("/stats", handler, WithMetadata[HandlerFunc]("feature", "stats"))
+	router.GET("/administrator", handler)
+
+	child := New[HandlerFunc]()
+	child.GET("/items", handler)
+	router.MountRouter("/shop", child)
+
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r, _ := newRequest(method, path, nil)
+		router.ServeHTTP(w, r)
+		return w
+	}
+	expect := func(method, path string, code int) {
+		t.Helper()
+		if w := serve(method, path); w.Code != code {
+			t.Errorf("%s %s: expected %d, got %d", method, path, code, w.Code)
+		}
+	}
+
+	n := router.DisableRoutes(And(MatchMethods("POST"), MatchPattern("/orders")), &Maintenance{
+		Reason:     "incident",
+		RetryAfter: 90 * time.Second,
+	})
+	if n != 1 {
+		t.Errorf("expected 1 route disabled, got %d", n)
+	}
+	w := serve("POST", "/orders")
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") != "90" {
+		t.Errorf("expected 503 with Retry-After, got %d %v", w.Code, w.Header())
+	}
+	expect("GET", "/orders", http.StatusOK)
+
+	if n := admin.Disable(nil); n != 2 {
+		t.Errorf("expected 2 routes disabled, got %d", n)
+	}
+	expect("GET", "/admin/users/1", http.StatusServiceUnavailable)
+	expect("HEAD", "/admin/users/1", http.StatusServiceUnavailable)
+	expect("GET", "/administrator", http.StatusOK)
+
+	router.DisableRoutes(MatchPattern("/shop/items"), &Maintenance{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusTeapot)
+		}),
+	})
+	expect("GET", "/shop/items", http.StatusTeapot)
+
+	disabled := router.DisabledRoutes()
+	want := []string{"GET /admin/stats", "GET /admin/users/:id", "POST /orders", "GET /shop/items"}
+	if len(disabled) != len(want) {
+		t.Fatalf("expected %d disabled routes, got %v", len(want), disabled)
+	}
+	for i, d := range disabled {
+		if got := d.Method + " " + d.Path; got != want[i] {
+			t.Errorf("expected disabled route %q, got %q", want[i], got)
+		}
+	}
+	if disabled[2].Maintenance.Reason != "incident" {
+		t.Errorf("expected the maintenance reason, got %q", disabled[2].Maintenance.Reason)
+	}
+
+	if n := router.EnableRoutes(MatchMetadata("feature", "stats")); n != 1 {
+		t.Errorf("expected 1 route enabled, got %d", n)
+	}
+	expect("GET", "/admin/stats", http.StatusOK)
+	expect("GET", "/admin/users/1", http.StatusServiceUnavailable)
+
+	if n := router.EnableRoutes(MatchPattern("/**")); n != 3 {
+		t.Errorf("expected 3 routes enabled, got %d", n)
+	}
+	expect("POST", "/orders", http.StatusOK)
+	expect("GET", "/admin/users/1", http.StatusOK)
+	expect("GET", "/shop/items", http.StatusOK)
+	if len(router.DisabledRoutes()) != 0 {
+		t.Errorf("expected no disabled routes, got %v", router.DisabledRoutes())
+	}
+}
+
+func TestDisabledRoutesHandler(t *testing.T) {
+	router := New[HandlerFunc]()
+	handler := func(w http.ResponseWriter, r *http.Request, params Params) {}
+	router.GET("/a", handler)
+	router.POST("/a", handler)
+	router.GET("/b", handler)
+
+	serve := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r, _ := newRequest("GET", "/admin/disabled-routes", nil)
+		router.DisabledRoutesHandler().ServeHTTP(w, r)
+		return w
+	}
+
+	tests := []struct {
+		change func()
+		want   string
+	}{
+		{func() {}, `[]`},
+		{
+			func() {
+				router.DisableRoutes(func(info *RouteInfo) bool { return info.Path == "/a" },
+					&Maintenance{Reason: "migration", RetryAfter: time.Minute})
+			},
+			`[{"method":"GET","path":"/a","reason":"migration","retry_after":60},` +
+				`{"method":"POST","path":"/a","reason":"migration","retry_after":60}]`,
+		},
+		{
+			func() {
+				router.EnableRoutes(func(info *RouteInfo) bool { return info.Method == "GET" })
+			},
+			`[{"method":"POST","path":"/a","reason":"migration","retry_after":60}]`,
+		},
+	}
+	for i, tt := range tests {
+		tt.change()
+		w := serve()
+		if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("%d: expected JSON, got %q", i, ct)
+		}
+		if got := strings.TrimSpace(w.Body.String()); got != tt.want {
+			t.Errorf("%d: got %s, want %s", i, got, tt.want)
+		}
+	}
+}
+
+func TestRouteHeaders(t *testing.T) {
+	router := New[HandlerFunc]()
+	handler := func(w http.ResponseWriter, r *http.Request, params Params) {}
+	router.GET("/disabled", handler)
+	router.GET("/deprecated", handler, WithDeprecation[HandlerFunc](&Deprecation{Successor: "/v2"}))
+	router.GET("/gone", handler, WithDeprecation[HandlerFunc](&Deprecation{
+		Sunset:          time.Now().Add(-time.Hour),
+		GoneAfterSunset: true,
+	}))
+	router.GET("/plain", handler)
+	router.DisableRoutes(func(info *RouteInfo) bool { return info.Path == "/disabled" },
+		&Maintenance{RetryAfter: time.Minute})
+
+	lookup := func(path string) LookupResult[HandlerFunc] {
+		lr, _ := router.LookupByPath("GET", path, path)
+		return lr
+	}
+
+	header, status := router.RouteHeaders(lookup("/disabled"))
+	if status != http.StatusServiceUnavailable || header.Get("Retry-After") != "60" {
+		t.Errorf("expected 503 with Retry-After, got %d %v", status, header)
+	}
+	header, status = router.RouteHeaders(lookup("/deprecated"))
+	if status != 0 || header.Get("Deprecation") != "true" || header.Get("Link") != `</v2>; rel="successor-version"` {
+		t.Errorf("expected the deprecation headers, got %d %v", status, header)
+	}
+	header, status = router.RouteHeaders(lookup("/gone"))
+	if status != http.StatusGone || header.Get("Sunset") == "" {
+		t.Errorf("expected 410 with Sunset, got %d %v", status, header)
+	}
+	if header, status = router.RouteHeaders(lookup("/plain")); status != 0 || header != nil {
+		t.Errorf("expected no headers, got %d %v", status, header)
+	}
+	if calls := lookup("/deprecated").Route().DeprecatedCalls(); calls != 1 {
+		t.Errorf("expected 1 deprecated call, got %d", calls)
+	}
+}
@@ -0,0 +1,235 @@
+package treemux
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// RequestMatcher matches a request by properties other than the method
+// and path, e.g. headers, query parameters, scheme and remote address.
+//
+// Matchers are given to a route by WithMatchers, they are evaluated after
+// the tree finds a node for the request path.
+type RequestMatcher interface {
+	// Match reports whether the request matches.
+	Match(r *http.Request) bool
+
+	// String returns a description of the matcher, which is used in
+	// route introspection.
+	String() string
+}
+
+type requestMatcher struct {
+	desc  string
+	match func(r *http.Request) bool
+}
+
+func (m *requestMatcher) Match(r *http.Request) bool { return m.match(r) }
+
+func (m *requestMatcher) String() string { return m.desc }
+
+// RequireFunc returns a RequestMatcher which calls fn to match requests,
+// name is used to describe the matcher.
+func RequireFunc(name string, fn func(r *http.Request) bool) RequestMatcher {
+	return &requestMatcher{desc: name, match: fn}
+}
+
+// RequireHeader returns a RequestMatcher which requires the request header
+// key to be value. If value is empty, it only requires the header to be
+// present.
+func RequireHeader(key, value string) RequestMatcher {
+	key = http.CanonicalHeaderKey(key)
+	if value == "" {
+		return &requestMatcher{
+			desc:  fmt.Sprintf("header %s", key),
+			match: func(r *http.Request) bool { return len(r.Header[key]) > 0 },
+		}
+	}
+	return &requestMatcher{
+		desc:  fmt.Sprintf("header %s=%s", key, value),
+		match: func(r *http.Request) bool { return r.Header.Get(key) == value },
+	}
+}
+
+// RequireHeaderRegexp returns a RequestMatcher which requires the request
+// header key to match the regular expression pattern.
+// It panics if pattern is not a valid regular expression.
+func RequireHeaderRegexp(key, pattern string) RequestMatcher {
+	key = http.CanonicalHeaderKey(key)
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		panic(fmt.Sprintf("treemux: regular expression %q is invalid: %v", pattern, err))
+	}
+	return &requestMatcher{
+		desc: fmt.Sprintf("header %s~%s", key, pattern),
+		match: func(r *http.Request) bool {
+			values, ok := r.Header[key]
+			return ok && re.MatchString(values[0])
+		},
+	}
+}
+
+// RequireQuery returns a RequestMatcher which requires the query parameter
+// key to be value. If value is empty, it only requires the parameter to
+// be present.
+func RequireQuery(key, value string) RequestMatcher {
+	desc := fmt.Sprintf("query %s=%s", key, value)
+	if value == "" {
+		desc = fmt.Sprintf("query %s", key)
+	}
+	return &requestMatcher{
+		desc: desc,
+		match: func(r *http.Request) bool {
+			if r.URL == nil {
+				return false
+			}
+			query, err := url.ParseQuery(r.URL.RawQuery)
+			if err != nil {
+				return false
+			}
+			values, ok := query[key]
+			if !ok {
+				return false
+			}
+			return value == "" || values[0] == value
+		},
+	}
+}
+
+// RequireScheme returns a RequestMatcher which requires the request's
+// scheme to be one of schemes.
+// The scheme is taken from the request URL if it is absolute, else it is
+// "https" for TLS connections and "http" otherwise. Headers like
+// `X-Forwarded-Proto` are not trusted.
+func RequireScheme(schemes ...string) RequestMatcher {
+	return &requestMatcher{
+		desc: fmt.Sprintf("scheme %s", strings.Join(schemes, "|")),
+		match: func(r *http.Request) bool {
+			scheme := getRequestScheme(r)
+			for _, s := range schemes {
+				if strings.EqualFold(s, scheme) {
+					return true
+				}
+			}
+			return false
+		},
+	}
+}
+
+func getRequestScheme(r *http.Request) string {
+	if r.URL != nil && r.URL.Scheme != "" {
+		return r.URL.Scheme
+	}
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// RequireRemoteCIDR returns a RequestMatcher which requires the request's
+// remote address to be in one of the CIDR blocks.
+// It panics if a CIDR block is invalid.
+func RequireRemoteCIDR(cidrs ...string) RequestMatcher {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(fmt.Sprintf("treemux: CIDR %q is invalid: %v", cidr, err))
+		}
+		nets = append(nets, ipNet)
+	}
+	return &requestMatcher{
+		desc: fmt.Sprintf("remote %s", strings.Join(cidrs, "|")),
+		match: func(r *http.Request) bool {
+			host, _, err := net.SplitHostPort(r.RemoteAddr)
+			if err != nil {
+				host = r.RemoteAddr
+			}
+			ip := net.ParseIP(host)
+			if ip == nil {
+				return false
+			}
+			for _, ipNet := range nets {
+				if ipNet.Contains(ip) {
+					return true
+				}
+			}
+			return false
+		},
+	}
+}
+
+// WithMatchers returns a RouteOption which guards the route by request
+// matchers, the route serves a request only if all the matchers match it.
+//
+// Several guarded handlers can be registered for the same method and
+// pattern. They are checked in the order of the number of matchers
+// descending, routes with the same number of matchers are checked in the
+// registering order. A handler registered without matchers serves the
+// requests which no guarded handler matches.
+//
+// If no handler matches a request, the router responds 405 if handlers
+// of other methods of the same pattern match the request, else it
+// responds 404.
+func WithMatchers[T HandlerConstraint](matchers ...RequestMatcher) RouteOption[T] {
+	return func(opts *routeOptions[T]) {
+		opts.matchers = append(opts.matchers, matchers...)
+	}
+}
+
+// emptyRequest is used to match requests when no request is available,
+// e.g. in Router.LookupByPath, it must not be modified.
+var emptyRequest = &http.Request{Header: http.Header{}, URL: &url.URL{}}
+
+func matchAll(matchers []RequestMatcher, r *http.Request) bool {
+	for _, m := range matchers {
+		if !m.Match(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// filterByMatchers returns variants whose matchers match the request.
+func filterByMatchers[T HandlerConstraint](variants []*variant[T], r *http.Request) []*variant[T] {
+	var out []*variant[T]
+	for i, v := range variants {
+		if !matchAll(v.route.Matchers, r) {
+			if out == nil {
+				out = make([]*variant[T], i, len(variants))
+				copy(out, variants[:i])
+			}
+			continue
+		}
+		if out != nil {
+			out = append(out, v)
+		}
+	}
+	if out == nil {
+		return variants
+	}
+	return out
+}
+
+// matchedMethods returns the methods which have a handler matching
+// the request.
+func (n *node[T]) matchedMethods(r *http.Request) (methods []string) {
+	for _, method := range n.allowedMethods() {
+		set := n.leafVariants[n.variantKey(method)]
+		if set == nil || set.hasDefault {
+			methods = append(methods, method)
+			continue
+		}
+		for _, v := range set.variants {
+			if matchAll(v.route.Matchers, r) {
+				methods = append(methods, method)
+				break
+			}
+		}
+	}
+	return methods
+}
@@ -0,0 +1,88 @@
+package treemux
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestRequestMatchers(t *testing.T) {
+	var result string
+	makeHandler := func(name string) HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request, params Params) {
+			result = name
+		}
+	}
+
+	router := New[HandlerFunc]()
+	router.GET("/items", makeHandler("beta"), WithMatchers[HandlerFunc](RequireHeader("X-Beta", "1")))
+	router.GET("/items", makeHandler("beta-internal"), WithMatchers[HandlerFunc](
+		RequireHeader("X-Beta", "1"),
+		RequireRemoteCIDR("10.0.0.0/8"),
+	))
+	router.GET("/items", makeHandler("legacy"), WithMatchers[HandlerFunc](RequireQuery("legacy", "")))
+	router.GET("/items", makeHandler("default"))
+
+	router.GET("/admin", makeHandler("admin-get"), WithMatchers[HandlerFunc](
+		RequireScheme("https"),
+		RequireHeaderRegexp("Authorization", "^Bearer "),
+	))
+	router.POST("/admin", makeHandler("admin-post"), WithMatchers[HandlerFunc](RequireScheme("https")))
+
+	tests := []struct {
+		method, path string
+		header       map[string]string
+		remoteAddr   string
+		tls          bool
+		wantCode     int
+		wantResult   string
+	}{
+		{"GET", "/items", nil, "", false, 200, "default"},
+		{"GET", "/items", map[string]string{"X-Beta": "1"}, "192.168.1.1:1234", false, 200, "beta"},
+		{"GET", "/items", map[string]string{"X-Beta": "1"}, "10.1.2.3:1234", false, 200, "beta-internal"},
+		{"GET", "/items?legacy", nil, "", false, 200, "legacy"},
+		{"GET", "/items?legacy=1", map[string]string{"X-Beta": "1"}, "", false, 200, "beta"},
+		{"GET", "/admin", map[string]string{"Authorization": "Bearer x"}, "", true, 200, "admin-get"},
+		{"GET", "/admin", map[string]string{"Authorization": "Basic x"}, "", true, 405, ""},
+		{"POST", "/admin", nil, "", true, 200, "admin-post"},
+		{"GET", "/admin", map[string]string{"Authorization": "Bearer x"}, "", false, 404, ""},
+	}
+	for _, tt := range tests {
+		result = ""
+		w := httptest.NewRecorder()
+		r, _ := newRequest(tt.method, tt.path, nil)
+		for k, v := range tt.header {
+			r.Header.Set(k, v)
+		}
+		if tt.remoteAddr != "" {
+			r.RemoteAddr = tt.remoteAddr
+		}
+		if tt.tls {
+			r.TLS = &tls.ConnectionState{}
+		}
+		router.ServeHTTP(w, r)
+		if w.Code != tt.wantCode || result != tt.wantResult {
+			t.Errorf("%s %s %v: got (%d, %q), wanted (%d, %q)",
+				tt.method, tt.path, tt.header, w.Code, result, tt.wantCode, tt.wantResult)
+		}
+		if w.Code == http.StatusMethodNotAllowed {
+			if allow := w.Header()["Allow"]; !reflect.DeepEqual(allow, []string{"POST"}) {
+				t.Errorf("unexpected Allow header %v", allow)
+			}
+		}
+	}
+
+	var descs []string
+	for _, route := range router.Routes() {
+		if route.Path == "/admin" && route.Method == "GET" {
+			for _, m := range route.Matchers {
+				descs = append(descs, m.String())
+			}
+		}
+	}
+	if want := []string{"scheme https", "header Authorization~^Bearer "}; !reflect.DeepEqual(descs, want) {
+		t.Errorf("got matchers %v, wanted %v", descs, want)
+	}
+}
@@ -0,0 +1,209 @@
+package treemux
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// MirrorPolicy configures shadow traffic mirroring for routes, see
+// WithMirror.
+//
+// A mirrored request is served by the route's handler as usual, and a
+// copy of it is served by Target asynchronously, the response of Target
+// is discarded. The status codes and body hashes of both responses are
+// compared, divergences are reported to OnDivergence.
+//
+// A MirrorPolicy can be shared by several routes, they share the
+// concurrency limit. It must not be modified after it is used.
+type MirrorPolicy struct {
+	// Target serves the mirrored requests. To mirror requests to an
+	// upstream, use a reverse proxy, e.g. [httputil.ReverseProxy].
+	Target http.Handler
+
+	// SampleRate is the fraction of requests to mirror, in range (0, 1].
+	// Zero means all requests are mirrored.
+	SampleRate float64
+
+	// MaxBodySize limits the size of request bodies which are buffered
+	// for mirroring, requests with a larger body are not mirrored.
+	// Zero means 1 MiB.
+	MaxBodySize int64
+
+	// MaxConcurrency limits the number of mirrored requests in flight,
+	// requests are not mirrored when the limit is reached.
+	// Zero means 16.
+	MaxConcurrency int
+
+	// Timeout limits the time to serve a mirrored request, the request's
+	// context is canceled after it. Zero means 10 seconds.
+	Timeout time.Duration
+
+	// OnDivergence, if not nil, is called when the responses of the
+	// route's handler and Target differ in status code or body.
+	// It is called in the goroutine which serves the mirrored request.
+	OnDivergence func(d MirrorDivergence)
+
+	initOnce sync.Once
+	sem      chan struct{}
+}
+
+// MirrorDivergence reports the difference between the response of a
+// route's handler and the response of the mirror target.
+type MirrorDivergence struct {
+	// Route is the route pattern of the request.
+	Route string
+
+	// Method and Path are the request method and URL path.
+	Method string
+	Path   string
+
+	PrimaryStatus   int
+	PrimaryBodyHash string
+	ShadowStatus    int
+	ShadowBodyHash  string
+}
+
+// WithMirror returns a RouteOption which mirrors the route's requests to
+// policy.Target. The mirroring runs after all the middlewares of the
+// route, thus requests rejected by middlewares are not mirrored.
+func WithMirror[T HandlerConstraint](policy *MirrorPolicy) RouteOption[T] {
+	if policy == nil {
+		policy = &MirrorPolicy{}
+	}
+	return func(opts *routeOptions[T]) {
+		opts.mirror = policy
+	}
+}
+
+func (p *MirrorPolicy) init() {
+	n := p.MaxConcurrency
+	if n <= 0 {
+		n = 16
+	}
+	p.sem = make(chan struct{}, n)
+}
+
+func (p *MirrorPolicy) timeout() time.Duration {
+	if p.Timeout <= 0 {
+		return 10 * time.Second
+	}
+	return p.Timeout
+}
+
+func (p *MirrorPolicy) maxBodySize() int64 {
+	if p.MaxBodySize <= 0 {
+		return 1 << 20
+	}
+	return p.MaxBodySize
+}
+
+// middleware returns an [http.Handler] middleware which mirrors requests
+// of the route pattern.
+func (p *MirrorPolicy) middleware(route string) HTTPHandlerMiddleware {
+	if p.Target == nil {
+		panic(fmt.Sprintf("treemux: mirror target of path %s is not configured", route))
+	}
+	p.initOnce.Do(p.init)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if p.SampleRate > 0 && p.SampleRate < 1 && rand.Float64() >= p.SampleRate {
+				next.ServeHTTP(w, r)
+				return
+			}
+			select {
+			case p.sem <- struct{}{}:
+			default:
+				// Too many mirrored requests in flight.
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			body, ok := bufferBody(r, p.maxBodySize())
+			if !ok {
+				<-p.sem
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			primary := make(chan *responseRecorder, 1)
+			shadowReq := r.Clone(detachedContext{r.Context()})
+			shadowReq.Body = io.NopCloser(bytes.NewReader(body))
+			shadowReq.ContentLength = int64(len(body))
+			go p.serveShadow(route, shadowReq, primary)
+
+			hw := &responseRecorder{ResponseWriter: w, hash: sha256.New()}
+			defer func() { primary <- hw }()
+			next.ServeHTTP(hw, r)
+		})
+	}
+}
+
+func (p *MirrorPolicy) serveShadow(route string, r *http.Request, primary <-chan *responseRecorder) {
+	defer func() { <-p.sem }()
+	defer func() {
+		// A panic in the mirror target must not crash the process.
+		_ = recover()
+	}()
+
+	ctx, cancel := context.WithTimeout(r.Context(), p.timeout())
+	defer cancel()
+	shadow := &responseRecorder{hash: sha256.New()}
+	p.Target.ServeHTTP(shadow, r.WithContext(ctx))
+	primaryResult := <-primary
+
+	if p.OnDivergence == nil {
+		return
+	}
+	d := MirrorDivergence{
+		Route:           route,
+		Method:          r.Method,
+		Path:            r.URL.Path,
+		PrimaryStatus:   primaryResult.status(),
+		PrimaryBodyHash: primaryResult.sum(),
+		ShadowStatus:    shadow.status(),
+		ShadowBodyHash:  shadow.sum(),
+	}
+	if d.PrimaryStatus != d.ShadowStatus || d.PrimaryBodyHash != d.ShadowBodyHash {
+		p.OnDivergence(d)
+	}
+}
+
+// bufferBody reads the request body if it is not larger than limit,
+// and restores the body for the next handler.
+func bufferBody(r *http.Request, limit int64) ([]byte, bool) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, true
+	}
+	if r.ContentLength > limit {
+		return nil, false
+	}
+	buf, err := io.ReadAll(io.LimitReader(r.Body, limit+1))
+	r.Body = readCloser{io.MultiReader(bytes.NewReader(buf), r.Body), r.Body}
+	if err != nil || int64(len(buf)) > limit {
+		return nil, false
+	}
+	return buf, true
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// detachedContext keeps the values of the parent context, but is not
+// canceled when the parent is canceled.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool)         { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}               { return nil }
+func (detachedContext) Err() error                          { return nil }
+func (c detachedContext) Value(key interface{}) interface{} { return c.parent.Value(key) }
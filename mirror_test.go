@@ -0,0 +1,146 @@
+package treemux
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMirror(t *testing.T) {
+	shadowBodies := make(chan string, 10)
+	divergences := make(chan MirrorDivergence, 10)
+	release := make(chan struct{})
+	policy := &MirrorPolicy{
+		Target: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			if r.Header.Get("X-Block") != "" {
+				<-release
+			}
+			if string(body) == "diverge" {
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+			w.Write(body)
+			shadowBodies <- string(body)
+		}),
+		MaxBodySize:    16,
+		MaxConcurrency: 1,
+		OnDivergence: func(d MirrorDivergence) {
+			divergences <- d
+		},
+	}
+
+	router := New[HandlerFunc]()
+	router.POST("/items/:id", func(w http.ResponseWriter, r *http.Request, params Params) {
+		body, _ := io.ReadAll(r.Body)
+		w.Write(body)
+	}, WithMirror[HandlerFunc](policy))
+
+	post := func(body string, header map[string]string) string {
+		w := httptest.NewRecorder()
+		r, _ := newRequest("POST", "/items/1", strings.NewReader(body))
+		for k, v := range header {
+			r.Header.Set(k, v)
+		}
+		router.ServeHTTP(w, r)
+		return w.Body.String()
+	}
+	waitShadow := func() string {
+		select {
+		case body := <-shadowBodies:
+			return body
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for the mirrored request")
+			return ""
+		}
+	}
+
+	if got := post("same", nil); got != "same" {
+		t.Errorf("got primary response %q", got)
+	}
+	if got := waitShadow(); got != "same" {
+		t.Errorf("got mirrored body %q", got)
+	}
+
+	post("diverge", nil)
+	waitShadow()
+	select {
+	case d := <-divergences:
+		if d.Route != "/items/:id" || d.Path != "/items/1" || d.PrimaryStatus != 200 || d.ShadowStatus != 500 {
+			t.Errorf("unexpected divergence %+v", d)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for divergence")
+	}
+
+	// Bodies larger than MaxBodySize are not mirrored,
+	// but the primary handler gets the whole body.
+	long := strings.Repeat("x", 100)
+	if got := post(long, nil); got != long {
+		t.Errorf("got primary response of length %d", len(got))
+	}
+
+	// Requests are not mirrored when the concurrency limit is reached.
+	post("blocked", map[string]string{"X-Block": "1"})
+	post("dropped", nil)
+	close(release)
+	if got := waitShadow(); got != "blocked" {
+		t.Errorf("got mirrored body %q, want blocked", got)
+	}
+	select {
+	case body := <-shadowBodies:
+		t.Errorf("unexpected mirrored body %q", body)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if len(divergences) != 0 {
+		t.Errorf("unexpected divergence %+v", <-divergences)
+	}
+}
+
+func TestMirrorWithoutTarget(t *testing.T) {
+	opt := WithMirror[HandlerFunc](&MirrorPolicy{})
+	defer func() {
+		if err := recover(); err == nil || !strings.Contains(fmt.Sprint(err), "/items/:id") {
+			t.Errorf("expected a panic naming the route, got %v", err)
+		}
+	}()
+	New[HandlerFunc]().POST("/items/:id", func(w http.ResponseWriter, r *http.Request, params Params) {}, opt)
+}
+
+func TestMirrorTimeout(t *testing.T) {
+	shadowErrs := make(chan error, 10)
+	policy := &MirrorPolicy{
+		Target: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("X-Hang") != "" {
+				<-r.Context().Done()
+			}
+			shadowErrs <- r.Context().Err()
+		}),
+		MaxConcurrency: 1,
+		Timeout:        20 * time.Millisecond,
+	}
+	router := New[HandlerFunc]()
+	router.GET("/items", func(w http.ResponseWriter, r *http.Request, params Params) {}, WithMirror[HandlerFunc](policy))
+
+	for _, hang := range []bool{true, false} {
+		r, _ := newRequest("GET", "/items", nil)
+		if hang {
+			r.Header.Set("X-Hang", "1")
+		}
+		router.ServeHTTP(httptest.NewRecorder(), r)
+		select {
+		case err := <-shadowErrs:
+			if hang && err != context.DeadlineExceeded || !hang && err != nil {
+				t.Errorf("hang=%v: unexpected context error %v", hang, err)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("hang=%v: timeout waiting for the mirrored request", hang)
+		}
+		// Wait for the slot to be released.
+		time.Sleep(10 * time.Millisecond)
+	}
+}
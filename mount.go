@@ -0,0 +1,250 @@
+package treemux
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// mountParamName is the name of the catch-all parameter which holds the
+// path under a mount point.
+const mountParamName = "mountpath"
+
+// Mount registers handler to serve all requests of any method under
+// prefix, including prefix itself. It is useful to embed handlers like
+// net/http/pprof, third-party admin UIs or a legacy [http.ServeMux].
+//
+// Before calling handler, the matched prefix is stripped from
+// URL.Path, URL.RawPath and RequestURI, the path segments are counted
+// on the path the router searches, according to Router.PathSource,
+// thus escaped slashes are handled consistently with routing.
+// The stripped path always begins with "/".
+//
+// The matched prefix is recorded in the request's ContextData, see
+// RouteData.MountPath, nested mounts accumulate their prefixes.
+// The handler is converted to T by Router.Bridge.ConvertMiddleware.
+//
+// prefix may contain wildcard and regexp segments, but not catch-all.
+func (g *Group[T]) Mount(prefix string, handler http.Handler, opts ...RouteOption[T]) {
+	g.mount(prefix, handler, nil, opts)
+}
+
+// MountRouter mounts child at prefix as a sub-router.
+//
+// Unlike Mount, the lookup descends into the child's routing tree,
+// the request is not modified. Routes registered to g take priority
+// over the routes of child. The result's RoutePath is the mount prefix
+// concatenated with the child's pattern, and Params contains the
+// parameters of both.
+//
+// The child keeps its own policies: redirects are decided by the child's
+// options, and the child's NotFoundHandler, MethodNotAllowedHandler and
+// other handlers serve the requests which don't match the child's routes.
+// Middlewares of g are not applied to the child's handlers.
+//
+// Routes of child are reported by Router.Routes with the prefix.
+// Routes can still be added to child after it is mounted.
+//
+// It panics if child is g's router, or g's router is mounted into
+// child directly or indirectly.
+func (g *Group[T]) MountRouter(prefix string, child *Router[T]) {
+	if child == g.mux {
+		panic("treemux: cannot mount a router into itself")
+	}
+	if child.mounts(g.mux) {
+		panic("treemux: cannot mount a router into its own sub-router")
+	}
+	g.mount(prefix, child, child, nil)
+}
+
+// mounts tells whether target is t or mounted into t, directly or
+// indirectly.
+func (t *Router[T]) mounts(target *Router[T]) bool {
+	if t == target {
+		return true
+	}
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	found := false
+	t.root.walk(func(n *node[T]) {
+		if !found && n.subRouter != nil {
+			found = n.subRouter.router.mounts(target)
+		}
+	})
+	return found
+}
+
+// subRouter is a child router mounted by Group.MountRouter.
+type subRouter[T HandlerConstraint] struct {
+	router   *Router[T]
+	prefix   string
+	segments int
+}
+
+func (g *Group[T]) mount(prefix string, handler http.Handler, child *Router[T], opts []RouteOption[T]) {
+	if g.mux.Bridge == nil {
+		panic("treemux: Bridge is not configured")
+	}
+	prefix = strings.TrimRight(prefix, "/")
+	if strings.Contains(prefix, "/*") {
+		panic("treemux: mount prefix must not contain catch-all segment")
+	}
+
+	fullPath := strings.TrimRight(g.path+prefix, "/")
+	h := &mountHandler[T]{
+		mux:      g.mux,
+		segments: strings.Count(fullPath, "/"),
+		handler:  handler,
+	}
+	mountFunc := g.mux.fromHTTPHandler(h)
+
+	if child != nil {
+		sub := &subRouter[T]{
+			router:   child,
+			prefix:   fullPath,
+			segments: h.segments,
+		}
+		opts = append(opts, func(opts *routeOptions[T]) {
+			opts.subRouter = sub
+		})
+	}
+
+	g.Any(prefix+"/*"+mountParamName, mountFunc, opts...)
+	if fullPath != "" {
+		g.Any(prefix, mountFunc, opts...)
+	}
+}
+
+// lookupSubRouter looks up the request in the sub-router mounted at n.
+// params is the reversed parameter values found by the parent.
+func (t *Router[T]) lookupSubRouter(n *node[T], params []string, method, requestURI, urlPath string, r *http.Request) (result LookupResult[T], found bool) {
+	sub := n.subRouter
+	childURI := requestURI
+	if requestURI != "" {
+		path, query, hasQuery := strings.Cut(requestURI, "?")
+		childURI, _ = stripPathSegments(path, sub.segments)
+		if hasQuery {
+			childURI += "?" + query
+		}
+	}
+	childPath, mounted := stripPathSegments(urlPath, sub.segments)
+
+	child := sub.router
+	if child.SafeAddRoutesWhileRunning {
+		child.mutex.RLock()
+		defer child.mutex.RUnlock()
+	}
+	result, found = child.lookup(method, childURI, childPath, r)
+	if result.router == nil {
+		result.router = child
+	}
+	if result.RedirectPath != "" {
+		if result.RedirectEscaped {
+			mounted = (&url.URL{Path: mounted}).EscapedPath()
+		}
+		result.RedirectPath = mounted + result.RedirectPath
+	}
+	if result.RoutePath != "" {
+		result.RoutePath = sub.prefix + result.RoutePath
+	}
+
+	// The parameters of the prefix, the catch-all value is excluded.
+	paramCount := len(n.leafParamNames)
+	if n.isCatchAll() {
+		paramCount--
+	}
+	if paramCount > 0 {
+		var merged Params
+		merged.Keys = make([]string, 0, paramCount+len(result.Params.Keys))
+		merged.Values = make([]string, 0, paramCount+len(result.Params.Values))
+		merged.Keys = append(merged.Keys, n.leafParamNames[:paramCount]...)
+		for i := len(params) - 1; i >= len(params)-paramCount; i-- {
+			merged.Values = append(merged.Values, params[i])
+		}
+		merged.Keys = append(merged.Keys, result.Params.Keys...)
+		merged.Values = append(merged.Values, result.Params.Values...)
+		result.Params = merged
+	}
+	return result, found
+}
+
+type mountHandler[T HandlerConstraint] struct {
+	mux      *Router[T]
+	segments int
+	handler  http.Handler
+}
+
+func (h *mountHandler[T]) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r2 := new(http.Request)
+	*r2 = *r
+	r2.URL = new(url.URL)
+	*r2.URL = *r.URL
+
+	var mounted string
+	if h.mux.PathSource == RequestURI && strings.HasPrefix(r.RequestURI, "/") {
+		path, query, hasQuery := strings.Cut(r.RequestURI, "?")
+		rest, stripped := stripPathSegments(path, h.segments)
+		unescapedRest, err := unescape(rest)
+		if err != nil {
+			unescapedRest = rest
+		}
+		mounted, err = unescape(stripped)
+		if err != nil {
+			mounted = stripped
+		}
+		r2.URL.Path = unescapedRest
+		r2.URL.RawPath = ""
+		if rest != r2.URL.EscapedPath() {
+			r2.URL.RawPath = rest
+		}
+		r2.RequestURI = rest
+		if hasQuery {
+			r2.RequestURI += "?" + query
+		}
+	} else {
+		var rest string
+		rest, mounted = stripPathSegments(r.URL.Path, h.segments)
+		r2.URL.Path = rest
+		r2.URL.RawPath = ""
+		if r.URL.RawPath != "" {
+			rawRest, _ := stripPathSegments(r.URL.RawPath, h.segments)
+			if unescaped, err := unescape(rawRest); err == nil && unescaped == rest {
+				r2.URL.RawPath = rawRest
+			}
+		}
+		if r.RequestURI != "" {
+			r2.RequestURI = r2.URL.RequestURI()
+		}
+	}
+
+	rd := GetRouteData(r)
+	r2 = AddContextData(r2, &routeData{
+		contextData:  contextData{route: rd.Route(), params: rd.Params()},
+		version:      rd.Version(),
+		variant:      rd.Variant(),
+		mediaType:    rd.MediaType(),
+		mountPath:    rd.MountPath() + mounted,
+		locale:       rd.Locale(),
+		implicitHead: rd.IsImplicitHead(),
+	})
+	h.handler.ServeHTTP(w, r2)
+}
+
+// stripPathSegments strips the leading n segments from path, it returns
+// the remaining path, which always begins with "/", and the stripped prefix.
+func stripPathSegments(path string, n int) (rest, stripped string) {
+	i := 0
+	for ; n > 0 && i < len(path); n-- {
+		j := strings.IndexByte(path[i+1:], '/')
+		if j < 0 {
+			i = len(path)
+			break
+		}
+		i += j + 1
+	}
+	rest = path[i:]
+	if rest == "" {
+		rest = "/"
+	}
+	return rest, path[:i]
+}
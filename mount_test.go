@@ -0,0 +1,184 @@
+package treemux
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestMount(t *testing.T) {
+	echo := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s|%s|%s|%s", r.URL.Path, r.URL.RawPath, r.RequestURI, GetRouteData(r).MountPath())
+	})
+	legacy := http.NewServeMux()
+	legacy.Handle("/hello", echo)
+
+	for _, pathSource := range []PathSource{RequestURI, URLPath} {
+		router := New[HandlerFunc]()
+		router.PathSource = pathSource
+		router.Mount("/legacy", legacy)
+		router.NewGroup("/tenants/:id").Mount("/admin/", echo)
+
+		tests := []struct {
+			method, path string
+			wantCode     int
+			wantBody     string
+		}{
+			{"GET", "/legacy/hello?a=1", 200, "/hello||/hello?a=1|/legacy"},
+			{"POST", "/legacy/hello", 200, "/hello||/hello|/legacy"},
+			{"GET", "/legacy/other", 404, ""},
+			{"DELETE", "/tenants/42/admin", 200, "/||/|/tenants/42/admin"},
+			{"GET", "/tenants/42/admin/", 301, ""},
+			{"GET", "/tenants/42/admin/a%2Fb", 200, "/a/b|/a%2Fb|/a%2Fb|/tenants/42/admin"},
+		}
+		for _, tt := range tests {
+			w := httptest.NewRecorder()
+			r, _ := newRequest(tt.method, tt.path, nil)
+			router.ServeHTTP(w, r)
+			if w.Code != tt.wantCode {
+				t.Errorf("%v %s %s: got code %d, want %d", pathSource, tt.method, tt.path, w.Code, tt.wantCode)
+				continue
+			}
+			if tt.wantCode == 200 && w.Body.String() != tt.wantBody {
+				t.Errorf("%v %s %s: got %q, want %q", pathSource, tt.method, tt.path, w.Body.String(), tt.wantBody)
+			}
+		}
+	}
+}
+
+func TestNestedMount(t *testing.T) {
+	inner := New[HTTPHandlerFunc]()
+	inner.UseContextData = true
+	inner.GET("/users/:name", func(w http.ResponseWriter, r *http.Request) {
+		rd := GetRouteData(r)
+		fmt.Fprintf(w, "%s %s %s", r.URL.Path, rd.MountPath(), rd.Param("name"))
+	})
+
+	middle := New[HandlerFunc]()
+	middle.Mount("/v1", inner)
+
+	outer := New[HTTPHandlerFunc]()
+	outer.Mount("/api", middle)
+
+	w := httptest.NewRecorder()
+	r, _ := newRequest("GET", "/api/v1/users/bob", nil)
+	outer.ServeHTTP(w, r)
+	if got, want := w.Body.String(), "/users/bob /api/v1 bob"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestStripPathSegments(t *testing.T) {
+	tests := []struct {
+		path        string
+		n           int
+		rest, strip string
+	}{
+		{"/a/b/c", 0, "/a/b/c", ""},
+		{"/a/b/c", 1, "/b/c", "/a"},
+		{"/a/b/c", 3, "/", "/a/b/c"},
+		{"/a/b/", 2, "/", "/a/b"},
+		{"/a", 2, "/", "/a"},
+	}
+	for _, tt := range tests {
+		rest, strip := stripPathSegments(tt.path, tt.n)
+		if rest != tt.rest || strip != tt.strip {
+			t.Errorf("stripPathSegments(%q, %d) = %q, %q, want %q, %q",
+				tt.path, tt.n, rest, strip, tt.rest, tt.strip)
+		}
+	}
+}
+
+func TestMountRouter(t *testing.T) {
+	var result string
+	makeHandler := func(name string) HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request, params Params) {
+			result = fmt.Sprintf("%s %s %v", name, GetContextData(r).Route(), params.Values)
+		}
+	}
+
+	child := New[HandlerFunc]()
+	child.UseContextData = true
+	child.RedirectBehavior = Redirect307
+	child.NotFoundHandler = func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}
+	child.GET("/", makeHandler("index"))
+	child.GET("/users/:name", makeHandler("user"))
+
+	parent := New[HandlerFunc]()
+	parent.NewGroup("/tenants/:tenant").MountRouter("/app", child)
+	parent.GET("/tenants/:tenant/app/health", makeHandler("health"))
+	child.POST("/users/:name", makeHandler("post-user"))
+
+	tests := []struct {
+		method, path string
+		wantCode     int
+		wantResult   string
+		wantLocation string
+	}{
+		{"GET", "/tenants/t1/app/users/bob", 200, "user /tenants/:tenant/app/users/:name [t1 bob]", ""},
+		{"POST", "/tenants/t1/app/users/bob", 200, "post-user /tenants/:tenant/app/users/:name [t1 bob]", ""},
+		{"GET", "/tenants/t1/app/", 200, "index /tenants/:tenant/app/ [t1]", ""},
+		{"GET", "/tenants/t1/app/health", 200, "health  [t1]", ""},
+		{"GET", "/tenants/t1/app/users/bob/", 307, "", "/tenants/t1/app/users/bob"},
+		{"GET", "/tenants/t1/app/missing", http.StatusTeapot, "", ""},
+		{"PUT", "/tenants/t1/app/users/bob", 405, "", ""},
+	}
+	for _, tt := range tests {
+		result = ""
+		w := httptest.NewRecorder()
+		r, _ := newRequest(tt.method, tt.path, nil)
+		parent.ServeHTTP(w, r)
+		if w.Code != tt.wantCode || result != tt.wantResult {
+			t.Errorf("%s %s: got %d %q, want %d %q", tt.method, tt.path, w.Code, result, tt.wantCode, tt.wantResult)
+		}
+		if got := w.Header().Get("Location"); got != tt.wantLocation {
+			t.Errorf("%s %s: got location %q, want %q", tt.method, tt.path, got, tt.wantLocation)
+		}
+	}
+
+	var paths []string
+	for _, route := range parent.Routes() {
+		paths = append(paths, route.Method+" "+route.Path)
+	}
+	want := []string{
+		"GET /tenants/:tenant/app/",
+		"GET /tenants/:tenant/app/health",
+		"GET /tenants/:tenant/app/users/:name",
+		"POST /tenants/:tenant/app/users/:name",
+	}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("got routes %v, want %v", paths, want)
+	}
+}
+
+func TestMountRouterCycle(t *testing.T) {
+	a, b, c := New[HandlerFunc](), New[HandlerFunc](), New[HandlerFunc]()
+	c.GET("/ping", func(w http.ResponseWriter, r *http.Request, params Params) {})
+	a.MountRouter("/b", b)
+	b.MountRouter("/c", c)
+
+	for _, tt := range []struct {
+		name          string
+		parent, child *Router[HandlerFunc]
+	}{
+		{"self", a, a},
+		{"direct", b, a},
+		{"indirect", c, a},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: expected a panic for the mount cycle", tt.name)
+				}
+			}()
+			tt.parent.MountRouter("/x", tt.child)
+		}()
+	}
+	if routes := a.Routes(); len(routes) != 1 || routes[0].Path != "/b/c/ping" {
+		t.Errorf("expected the route of the nested router, got %v", routes)
+	}
+}
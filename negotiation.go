@@ -0,0 +1,208 @@
+package treemux
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// WithProduces returns a RouteOption which declares the media types
+// the route's handler produces.
+//
+// Several handlers can be registered for the same method and pattern
+// with different media types, the router selects the handler by the
+// request's `Accept` header, the chosen media type is reported by
+// LookupResult.MediaType and RouteData.MediaType.
+// If no handler is acceptable, the request is served by
+// Router.NotAcceptableHandler, unless a handler without media type
+// constraints is registered for the same method and pattern.
+func WithProduces[T HandlerConstraint](mediaTypes ...string) RouteOption[T] {
+	return func(opts *routeOptions[T]) {
+		opts.produces = append(opts.produces, mediaTypes...)
+	}
+}
+
+// WithConsumes returns a RouteOption which declares the media types
+// the route's handler accepts as request body.
+//
+// Several handlers can be registered for the same method and pattern
+// with different media types, the router selects the handler by the
+// request's `Content-Type` header.
+// If no handler supports the request's content type, the request is
+// served by Router.UnsupportedMediaTypeHandler, unless a handler without
+// media type constraints is registered for the same method and pattern.
+func WithConsumes[T HandlerConstraint](mediaTypes ...string) RouteOption[T] {
+	return func(opts *routeOptions[T]) {
+		opts.consumes = append(opts.consumes, mediaTypes...)
+	}
+}
+
+// defaultNotAcceptableHandler is the default handler for
+// Router.NotAcceptableHandler, it writes the status code
+// http.StatusNotAcceptable.
+func defaultNotAcceptableHandler(w http.ResponseWriter, _ *http.Request) {
+	w.WriteHeader(http.StatusNotAcceptable)
+}
+
+// defaultUnsupportedMediaTypeHandler is the default handler for
+// Router.UnsupportedMediaTypeHandler, it writes the status code
+// http.StatusUnsupportedMediaType.
+func defaultUnsupportedMediaTypeHandler(w http.ResponseWriter, _ *http.Request) {
+	w.WriteHeader(http.StatusUnsupportedMediaType)
+}
+
+type mediaRange struct {
+	value   string
+	typ     string
+	subtype string
+	q       float64
+}
+
+// specificity tells how specific the media range is,
+// "type/subtype" > "type/*" > "*/*".
+func (m mediaRange) specificity() int {
+	if m.typ == "*" {
+		return 1
+	}
+	if m.subtype == "*" {
+		return 2
+	}
+	return 3
+}
+
+func (m mediaRange) match(typ, subtype string) bool {
+	return (m.typ == "*" || m.typ == typ) &&
+		(m.subtype == "*" || m.subtype == subtype)
+}
+
+func parseMediaRange(s string) (m mediaRange, ok bool) {
+	params := ""
+	if i := strings.IndexByte(s, ';'); i >= 0 {
+		s, params = s[:i], s[i+1:]
+	}
+	s = strings.ToLower(strings.TrimSpace(s))
+	slash := strings.IndexByte(s, '/')
+	if slash <= 0 || slash == len(s)-1 {
+		return m, false
+	}
+	m = mediaRange{value: s, typ: s[:slash], subtype: s[slash+1:], q: 1}
+	for params != "" {
+		var param string
+		if i := strings.IndexByte(params, ';'); i >= 0 {
+			param, params = params[:i], params[i+1:]
+		} else {
+			param, params = params, ""
+		}
+		key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+		if strings.EqualFold(strings.TrimSpace(key), "q") {
+			q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil || q < 0 || q > 1 {
+				return m, false
+			}
+			m.q = q
+		}
+	}
+	return m, true
+}
+
+func parseMediaRanges(list []string) (out []mediaRange) {
+	for _, s := range list {
+		for _, part := range strings.Split(s, ",") {
+			if m, ok := parseMediaRange(part); ok {
+				out = append(out, m)
+			}
+		}
+	}
+	return out
+}
+
+// quality returns the quality factor of media type m given the
+// ranges parsed from an `Accept` header, the most specific matching
+// range determines the quality.
+func quality(accepts []mediaRange, m mediaRange) float64 {
+	q, specificity := 0.0, 0
+	for _, a := range accepts {
+		if a.match(m.typ, m.subtype) && a.specificity() > specificity {
+			q, specificity = a.q, a.specificity()
+		}
+	}
+	return q
+}
+
+// filterByContentType returns variants which consume the content type.
+// If no variant supports the content type, it returns status code
+// http.StatusUnsupportedMediaType.
+func filterByContentType[T HandlerConstraint](variants []*variant[T], contentType string) ([]*variant[T], int) {
+	ct, ctOK := parseMediaRange(contentType)
+	accepted := func(v *variant[T]) bool {
+		if len(v.consumes) == 0 {
+			return true
+		}
+		if !ctOK {
+			return false
+		}
+		for _, c := range v.consumes {
+			if c.match(ct.typ, ct.subtype) {
+				return true
+			}
+		}
+		return false
+	}
+
+	var out []*variant[T]
+	for i, v := range variants {
+		if !accepted(v) {
+			if out == nil {
+				out = make([]*variant[T], i, len(variants))
+				copy(out, variants[:i])
+			}
+			continue
+		}
+		if out != nil {
+			out = append(out, v)
+		}
+	}
+	if out == nil {
+		out = variants
+	}
+	if len(out) == 0 {
+		return nil, http.StatusUnsupportedMediaType
+	}
+	return out, 0
+}
+
+// negotiateAccept selects the variant which best matches the `Accept`
+// header. Variants which declare media types are preferred, a variant
+// without media types is selected only if no declared media type is
+// acceptable. Ties are broken by the registering order.
+// If no variant is acceptable, it returns status code
+// http.StatusNotAcceptable.
+func negotiateAccept[T HandlerConstraint](variants []*variant[T], accept string) (best *variant[T], mediaType string, statusCode int) {
+	accepts := parseMediaRanges([]string{accept})
+	if len(accepts) == 0 {
+		accepts = []mediaRange{{value: "*/*", typ: "*", subtype: "*", q: 1}}
+	}
+
+	var fallback *variant[T]
+	bestQ := 0.0
+	for _, v := range variants {
+		if len(v.produces) == 0 {
+			if fallback == nil {
+				fallback = v
+			}
+			continue
+		}
+		for _, p := range v.produces {
+			if q := quality(accepts, p); q > bestQ {
+				best, mediaType, bestQ = v, p.value, q
+			}
+		}
+	}
+	if best == nil {
+		if fallback != nil {
+			return fallback, "", 0
+		}
+		return nil, "", http.StatusNotAcceptable
+	}
+	return best, mediaType, 0
+}
@@ -0,0 +1,127 @@
+package treemux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestContentNegotiation(t *testing.T) {
+	var result, mediaType string
+	makeHandler := func(name string) HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request, params Params) {
+			result = name
+			mediaType = GetRouteData(r).MediaType()
+		}
+	}
+
+	router := New[HandlerFunc]()
+	router.UseContextData = true
+	router.GET("/users/:id", makeHandler("json"), WithProduces[HandlerFunc]("application/json"))
+	router.GET("/users/:id", makeHandler("html"), WithProduces[HandlerFunc]("text/html"))
+	router.GET("/users/:id", makeHandler("proto"), WithProduces[HandlerFunc]("application/x-protobuf"))
+	router.POST("/users", makeHandler("post-json"), WithConsumes[HandlerFunc]("application/json"))
+	router.POST("/users", makeHandler("post-form"), WithConsumes[HandlerFunc]("application/x-www-form-urlencoded", "multipart/*"))
+	router.GET("/reports", makeHandler("csv"), WithProduces[HandlerFunc]("text/csv"))
+	router.GET("/reports", makeHandler("default"))
+
+	tests := []struct {
+		method, path string
+		header       map[string]string
+		wantCode     int
+		wantResult   string
+		wantType     string
+	}{
+		{"GET", "/users/1", nil, 200, "json", "application/json"},
+		{"GET", "/users/1", map[string]string{"Accept": "text/html"}, 200, "html", "text/html"},
+		{"GET", "/users/1", map[string]string{"Accept": "text/html;q=0.5, application/x-protobuf"}, 200, "proto", "application/x-protobuf"},
+		{"GET", "/users/1", map[string]string{"Accept": "text/*;q=0.9, */*;q=0.1"}, 200, "html", "text/html"},
+		{"GET", "/users/1", map[string]string{"Accept": "application/json;q=0, */*"}, 200, "html", "text/html"},
+		{"GET", "/users/1", map[string]string{"Accept": "image/png"}, 406, "", ""},
+		{"HEAD", "/users/1", map[string]string{"Accept": "text/html"}, 200, "html", "text/html"},
+		{"POST", "/users", map[string]string{"Content-Type": "application/json; charset=utf-8"}, 200, "post-json", ""},
+		{"POST", "/users", map[string]string{"Content-Type": "multipart/form-data; boundary=x"}, 200, "post-form", ""},
+		{"POST", "/users", map[string]string{"Content-Type": "text/plain"}, 415, "", ""},
+		{"GET", "/reports", map[string]string{"Accept": "text/csv"}, 200, "csv", "text/csv"},
+		{"GET", "/reports", map[string]string{"Accept": "application/json"}, 200, "default", ""},
+	}
+	for _, tt := range tests {
+		result, mediaType = "", ""
+		w := httptest.NewRecorder()
+		r, _ := newRequest(tt.method, tt.path, nil)
+		for k, v := range tt.header {
+			r.Header.Set(k, v)
+		}
+		router.ServeHTTP(w, r)
+		if w.Code != tt.wantCode || result != tt.wantResult || mediaType != tt.wantType {
+			t.Errorf("%s %s %v: got (%d, %q, %q), wanted (%d, %q, %q)",
+				tt.method, tt.path, tt.header, w.Code, result, mediaType,
+				tt.wantCode, tt.wantResult, tt.wantType)
+		}
+	}
+
+	r, _ := newRequest("GET", "/users/1", nil)
+	r.Header.Set("Accept", "text/html")
+	lr, found := router.Lookup(nil, r)
+	if !found || lr.MediaType != "text/html" {
+		t.Errorf("unexpected lookup result: found= %v, media type= %q", found, lr.MediaType)
+	}
+
+	var produces []string
+	for _, route := range router.Routes() {
+		if route.Path == "/users/:id" {
+			produces = append(produces, strings.Join(route.Produces, ","))
+		}
+	}
+	if len(produces) != 3 {
+		t.Errorf("expected 3 routes of /users/:id, got %v", produces)
+	}
+}
+
+func TestDuplicateDefaultHandlerWithVariants(t *testing.T) {
+	defer func() {
+		if err := recover(); err == nil {
+			t.Error("duplicate default handler should have caused a panic")
+		}
+	}()
+	router := New[HandlerFunc]()
+	router.GET("/a", simpleHandler, WithProduces[HandlerFunc]("text/html"))
+	router.GET("/a", simpleHandler)
+	router.GET("/a", simpleHandler)
+}
+
+func TestContentNegotiationVary(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request, params Params) {}
+	router := New[HandlerFunc]()
+	router.GET("/users/:id", handler, WithProduces[HandlerFunc]("application/json"))
+	router.GET("/users/:id", handler, WithProduces[HandlerFunc]("text/html"))
+	router.POST("/users", handler, WithConsumes[HandlerFunc]("application/json"))
+	router.POST("/users", handler)
+	router.GET("/plain", handler)
+	router.GET("/matched", handler, WithMatchers[HandlerFunc](RequireHeader("X-Beta", "1")))
+
+	tests := []struct {
+		method, path string
+		header       map[string]string
+		wantVary     []string
+	}{
+		{"GET", "/users/1", map[string]string{"Accept": "text/html"}, []string{"Accept"}},
+		{"GET", "/users/1", map[string]string{"Accept": "image/png"}, []string{"Accept"}},
+		{"POST", "/users", map[string]string{"Content-Type": "application/json"}, []string{"Content-Type"}},
+		{"POST", "/users", map[string]string{"Content-Type": "text/plain"}, []string{"Content-Type"}},
+		{"GET", "/plain", nil, nil},
+		{"GET", "/matched", map[string]string{"X-Beta": "1"}, nil},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r, _ := newRequest(tt.method, tt.path, nil)
+		for k, v := range tt.header {
+			r.Header.Set(k, v)
+		}
+		router.ServeHTTP(w, r)
+		if got := w.Header().Values("Vary"); strings.Join(got, ",") != strings.Join(tt.wantVary, ",") {
+			t.Errorf("%s %s %v: got Vary %v, wanted %v", tt.method, tt.path, tt.header, got, tt.wantVary)
+		}
+	}
+}
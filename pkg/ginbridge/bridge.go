@@ -1,7 +1,9 @@
 package ginbridge
 
 import (
+	"context"
 	"net/http"
+	"net/url"
 	"sync/atomic"
 	"unsafe"
 
@@ -57,7 +59,25 @@ func (b *Bridge) Serve(c *gin.Context) {
 	lr, _ := mux.Lookup(c.Writer, c.Request)
 
 	if lr.RedirectPath != "" {
-		c.Redirect(lr.StatusCode, lr.RedirectPath)
+		location := lr.RedirectPath
+		if !lr.RedirectEscaped {
+			location = (&url.URL{Path: location}).EscapedPath()
+		}
+		c.Redirect(lr.StatusCode, location)
+		return
+	}
+
+	for _, h := range lr.Vary {
+		c.Writer.Header().Add("Vary", h)
+	}
+	header, preflight := mux.CORSHeaders(lr, c.Request.Method, c.Request.Header.Get)
+	for k, v := range header {
+		for _, x := range v {
+			c.Writer.Header().Add(k, x)
+		}
+	}
+	if preflight {
+		c.AbortWithStatus(http.StatusNoContent)
 		return
 	}
 
@@ -66,8 +86,39 @@ func (b *Bridge) Serve(c *gin.Context) {
 		val := lr.Params.Values[i]
 		c.AddParam(key, val)
 	}
+	if len(lr.Params.Keys) > 0 {
+		// The http middlewares converted by ConvertMiddleware, like the
+		// proxy and cache of treemux, take the params from the request.
+		c.Request = c.Request.WithContext(treemux.AddParamsToContext(c.Request.Context(), lr.Params))
+	}
 
 	if lr.Handler != nil {
+		header, status := mux.RouteHeaders(lr)
+		for k, v := range header {
+			for _, x := range v {
+				c.Writer.Header().Add(k, x)
+			}
+		}
+		if status != 0 {
+			c.AbortWithStatus(status)
+			return
+		}
+		if route := lr.Route(); route != nil {
+			if route.MaxBodySize > 0 {
+				if c.Request.ContentLength > route.MaxBodySize {
+					c.AbortWithStatus(http.StatusRequestEntityTooLarge)
+					return
+				}
+				if c.Request.Body != nil && c.Request.Body != http.NoBody {
+					c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, route.MaxBodySize)
+				}
+			}
+			if route.Timeout > 0 {
+				ctx, cancel := context.WithTimeout(c.Request.Context(), route.Timeout)
+				defer cancel()
+				c.Request = c.Request.WithContext(ctx)
+			}
+		}
 		lr.Handler.run(lr.RoutePath, c)
 		return
 	}
@@ -84,14 +135,14 @@ func (b *Bridge) Serve(c *gin.Context) {
 }
 
 // GetRouter returns the current router attached to this bridge.
-func (b *Bridge) GetRouter() *treemux.TreeMux[*Handler] {
-	return (*treemux.TreeMux[*Handler])(atomic.LoadPointer(&b.mux))
+func (b *Bridge) GetRouter() *treemux.Router[*Handler] {
+	return (*treemux.Router[*Handler])(atomic.LoadPointer(&b.mux))
 }
 
 // SetRouter changes the router of the bridge, it's safe to change the bridge's
 // router concurrently.
 // It also assigns the bridge to router.Bridge.
-func (b *Bridge) SetRouter(mux *treemux.TreeMux[*Handler]) {
+func (b *Bridge) SetRouter(mux *treemux.Router[*Handler]) {
 	mux.Bridge = b
 	atomic.StorePointer(&b.mux, unsafe.Pointer(mux))
 }
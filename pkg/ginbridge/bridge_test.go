@@ -0,0 +1,120 @@
+package ginbridge
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jxskiss/treemux"
+)
+
+func TestBridgeRoutePolicies(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := treemux.New[*Handler]()
+	bridge := New()
+	bridge.SetRouter(router)
+
+	var deadline bool
+	ok := WrapHandler(func(c *gin.Context) {
+		_, deadline = c.Request.Context().Deadline()
+		if _, err := io.ReadAll(c.Request.Body); err != nil {
+			c.Status(http.StatusRequestEntityTooLarge)
+			return
+		}
+		c.String(http.StatusOK, "ok")
+	})
+	router.GET("/disabled", ok)
+	router.GET("/deprecated", ok, treemux.WithDeprecation[*Handler](&treemux.Deprecation{
+		Sunset:          time.Now().Add(-time.Hour),
+		GoneAfterSunset: true,
+	}))
+	router.POST("/upload", ok, treemux.WithMaxBodySize[*Handler](4), treemux.WithTimeout[*Handler](time.Minute))
+	router.DisableRoutes(func(info *treemux.RouteInfo) bool { return info.Path == "/disabled" },
+		&treemux.Maintenance{RetryAfter: time.Minute})
+
+	eng := gin.New()
+	eng.Any("/*any", bridge.Serve)
+
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		eng.ServeHTTP(w, r)
+		return w
+	}
+
+	if w := serve("GET", "/disabled", ""); w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") != "60" {
+		t.Errorf("expected 503 with Retry-After, got %d %v", w.Code, w.Header())
+	}
+	if w := serve("GET", "/deprecated", ""); w.Code != http.StatusGone || w.Header().Get("Sunset") == "" {
+		t.Errorf("expected 410 with Sunset, got %d %v", w.Code, w.Header())
+	}
+	if w := serve("POST", "/upload", "12345"); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413, got %d", w.Code)
+	}
+	if w := serve("POST", "/upload", "123"); w.Code != http.StatusOK || !deadline {
+		t.Errorf("expected 200 with a deadline, got %d %v", w.Code, deadline)
+	}
+}
+
+func TestBridgeHTTPMiddlewareParams(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := treemux.New[*Handler]()
+	bridge := New()
+	bridge.SetRouter(router)
+
+	var got string
+	router.UseHandler(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = treemux.GetContextData(r).Param("id")
+			next.ServeHTTP(w, r)
+		})
+	})
+	router.GET("/users/:id", WrapHandler(func(c *gin.Context) {
+		c.String(http.StatusOK, c.Param("id"))
+	}))
+
+	eng := gin.New()
+	eng.Any("/*any", bridge.Serve)
+	w := httptest.NewRecorder()
+	eng.ServeHTTP(w, httptest.NewRequest("GET", "/users/42", nil))
+	if w.Code != http.StatusOK || got != "42" {
+		t.Errorf("expected the params in the http middleware, got %d %q", w.Code, got)
+	}
+}
+
+func TestBridgeRedirect(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := treemux.New[*Handler]()
+	bridge := New()
+	bridge.SetRouter(router)
+
+	ok := WrapHandler(func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+	router.GET("/users/:id", ok, treemux.WithAlias[*Handler](treemux.Alias{Pattern: "/people/:id", Redirect: true}))
+	router.GET("/files/:name/", ok)
+
+	eng := gin.New()
+	eng.Any("/*any", bridge.Serve)
+
+	tests := []struct {
+		path     string
+		location string
+	}{
+		{"/people/a%2Fb", "/users/a%2Fb"},
+		{"/files/a%20b", "/files/a%20b/"},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		eng.ServeHTTP(w, httptest.NewRequest("GET", test.path, nil))
+		if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != test.location {
+			t.Errorf("%s: expected redirect to %s, got %d %q",
+				test.path, test.location, w.Code, w.Header().Get("Location"))
+		}
+	}
+}
@@ -29,3 +29,5 @@ require (
 )
 
 retract v0.2.0
+
+replace github.com/jxskiss/treemux => ../..
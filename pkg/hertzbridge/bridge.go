@@ -3,6 +3,7 @@ package hertzbridge
 import (
 	"context"
 	"net/http"
+	"net/url"
 	"sync/atomic"
 	"unsafe"
 
@@ -30,25 +31,62 @@ func (*Bridge) IsHandlerValid(handler *Handler) bool {
 }
 
 func (b *Bridge) Serve(ctx context.Context, rc *app.RequestContext) {
-	method := string(rc.Method())
-	requestURI := string(rc.Request.RequestURI())
-	urlPath := string(rc.URI().Path())
+	r := newRequest(ctx, rc)
 
 	mux := b.GetRouter()
-	lr, _ := mux.LookupByPath(method, requestURI, urlPath)
+	lr, _ := mux.Lookup(nil, r)
 
 	if lr.RedirectPath != "" {
-		rc.Redirect(lr.StatusCode, []byte(lr.RedirectPath))
+		location := lr.RedirectPath
+		if !lr.RedirectEscaped {
+			location = (&url.URL{Path: location}).EscapedPath()
+		}
+		rc.Redirect(lr.StatusCode, []byte(location))
+		return
+	}
+
+	for _, h := range lr.Vary {
+		rc.Response.Header.Add("Vary", h)
+	}
+	header, preflight := mux.CORSHeaders(lr, r.Method, r.Header.Get)
+	for k, v := range header {
+		for _, x := range v {
+			rc.Response.Header.Add(k, x)
+		}
+	}
+	if preflight {
+		rc.AbortWithStatus(http.StatusNoContent)
 		return
 	}
 
 	rc.Params = rc.Params[:0]
 	for i, key := range lr.Params.Keys {
 		val := lr.Params.Values[i]
-		rc.Params = append(rc.Params, param.Param{key, val})
+		rc.Params = append(rc.Params, param.Param{Key: key, Value: val})
 	}
 
 	if lr.Handler != nil {
+		header, status := mux.RouteHeaders(lr)
+		for k, v := range header {
+			for _, x := range v {
+				rc.Response.Header.Add(k, x)
+			}
+		}
+		if status != 0 {
+			rc.AbortWithStatus(status)
+			return
+		}
+		if route := lr.Route(); route != nil {
+			if route.MaxBodySize > 0 && int64(len(rc.Request.Body())) > route.MaxBodySize {
+				rc.AbortWithStatus(http.StatusRequestEntityTooLarge)
+				return
+			}
+			if route.Timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, route.Timeout)
+				defer cancel()
+			}
+		}
 		lr.Handler.run(lr.RoutePath, ctx, rc)
 		return
 	}
@@ -64,15 +102,45 @@ func (b *Bridge) Serve(ctx context.Context, rc *app.RequestContext) {
 	rc.NotFound()
 }
 
+// newRequest builds an *http.Request from rc, it carries what the router
+// uses to select a route: the method, URL, host, remote address and
+// headers. The body is not carried.
+func newRequest(ctx context.Context, rc *app.RequestContext) *http.Request {
+	r := &http.Request{
+		Method: string(rc.Method()),
+		URL: &url.URL{
+			Scheme:   string(rc.URI().Scheme()),
+			Host:     string(rc.Host()),
+			Path:     string(rc.URI().Path()),
+			RawQuery: string(rc.URI().QueryString()),
+		},
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        make(http.Header),
+		Body:          http.NoBody,
+		ContentLength: int64(rc.Request.Header.ContentLength()),
+		Host:          string(rc.Host()),
+		RequestURI:    string(rc.Request.RequestURI()),
+	}
+	rc.Request.Header.VisitAll(func(key, value []byte) {
+		r.Header.Add(string(key), string(value))
+	})
+	if addr := rc.RemoteAddr(); addr != nil {
+		r.RemoteAddr = addr.String()
+	}
+	return r.WithContext(ctx)
+}
+
 // GetRouter returns the current router attached to this bridge.
-func (b *Bridge) GetRouter() *treemux.TreeMux[*Handler] {
-	return (*treemux.TreeMux[*Handler])(atomic.LoadPointer(&b.mux))
+func (b *Bridge) GetRouter() *treemux.Router[*Handler] {
+	return (*treemux.Router[*Handler])(atomic.LoadPointer(&b.mux))
 }
 
 // SetRouter changes the router of the bridge, it's safe to change
 // the bridge's router concurrently.
 // It also assigns the bridge to router.Bridge.
-func (b *Bridge) SetRouter(mux *treemux.TreeMux[*Handler]) {
+func (b *Bridge) SetRouter(mux *treemux.Router[*Handler]) {
 	mux.Bridge = b
 	atomic.StorePointer(&b.mux, unsafe.Pointer(mux))
 }
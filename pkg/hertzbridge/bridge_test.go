@@ -0,0 +1,47 @@
+package hertzbridge
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/jxskiss/treemux"
+)
+
+func TestBridgeRequestMatchers(t *testing.T) {
+	router := treemux.New[*Handler]()
+	bridge := New()
+	bridge.SetRouter(router)
+
+	reply := func(body string) *Handler {
+		return WrapHandler(func(ctx context.Context, c *app.RequestContext) {
+			c.String(200, body)
+		})
+	}
+	router.GET("/items", reply("beta"), treemux.WithMatchers[*Handler](treemux.RequireHeader("X-Beta", "1")))
+	router.GET("/items", reply("stable"))
+	router.GET("/v", reply("v2"), treemux.WithVersion[*Handler]("2", false))
+	router.GET("/v", reply("v1"), treemux.WithVersion[*Handler]("1", false))
+
+	eng := route.NewEngine(config.NewOptions(nil))
+	eng.Any("/*any", bridge.Serve)
+
+	for _, tc := range []struct {
+		path   string
+		header ut.Header
+		want   string
+	}{
+		{"/items", ut.Header{Key: "X-Beta", Value: "1"}, "beta"},
+		{"/items", ut.Header{Key: "X-Other", Value: "1"}, "stable"},
+		{"/v", ut.Header{Key: "X-API-Version", Value: "1"}, "v1"},
+		{"/v", ut.Header{Key: "X-API-Version", Value: "2"}, "v2"},
+	} {
+		w := ut.PerformRequest(eng, "GET", tc.path, nil, tc.header)
+		if got := string(w.Body.Bytes()); w.Code != 200 || got != tc.want {
+			t.Errorf("%s %v: expected %q, got %d %q", tc.path, tc.header, tc.want, w.Code, got)
+		}
+	}
+}
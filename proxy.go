@@ -0,0 +1,276 @@
+package treemux
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ProxyOptions configures a reverse proxy created by NewProxy.
+type ProxyOptions struct {
+	// Rewrites is the list of functions to rewrite the upstream path,
+	// they are applied in order after the target template is populated.
+	// They are applied to the escaped path, thus an escaped parameter
+	// value like "a%2Fb" stays one segment. See NewRewriteFunc.
+	Rewrites []RewriteFunc
+
+	// Timeout limits the time of a proxied request, including reading
+	// the response body. Zero means no timeout.
+	// On timeout, the client receives 504 Gateway Timeout if the response
+	// header has not been written.
+	Timeout time.Duration
+
+	// PreserveHost tells to send the incoming Host header to the upstream,
+	// instead of the host of the target.
+	PreserveHost bool
+
+	// TrustForwardedHeaders tells to keep the `X-Forwarded-For`,
+	// `X-Forwarded-Host` and `X-Forwarded-Proto` headers sent by the
+	// client, it should only be set if the proxy is behind another
+	// trusted proxy. By default, they are overwritten, so that clients
+	// can not spoof them.
+	TrustForwardedHeaders bool
+
+	// FlushInterval is the flush interval passed to
+	// [httputil.ReverseProxy], a negative value means to flush
+	// immediately after each write.
+	FlushInterval time.Duration
+
+	// Transport is used to perform proxy requests.
+	// If nil, [http.DefaultTransport] is used.
+	Transport http.RoundTripper
+
+	// ModifyResponse, if not nil, modifies the response from the upstream,
+	// see [httputil.ReverseProxy].
+	ModifyResponse func(*http.Response) error
+
+	// ErrorHandler, if not nil, handles errors reaching the upstream.
+	// By default, it responds 503 for ErrNoHealthyUpstream, 504 for
+	// timeout and 502 for other errors.
+	ErrorHandler func(w http.ResponseWriter, r *http.Request, err error)
+
+	// Pool, if not nil, is the pool of upstreams to forward requests to,
+	// the scheme and host of the target are replaced by the upstream
+	// picked from the pool, and the target can be a path template like
+	// "/internal/users/:id". See Router.AddUpstreamPool.
+	Pool *UpstreamPool
+}
+
+// Proxy is a reverse proxy which forwards requests to an upstream URL
+// populated from the path parameters. It is created by NewProxy, and
+// registered to routes by Group.Proxy.
+type Proxy struct {
+	target  *url.URL
+	options ProxyOptions
+	proxy   *httputil.ReverseProxy
+}
+
+// NewProxy creates a Proxy which forwards requests to target.
+//
+// target is an absolute URL template, segments of its path in the form
+// ":name" and "*name" are replaced by the path parameters of the
+// request, e.g. "http://users-svc/internal/users/:id". If target has
+// no path, the request path is used. The query of target is merged with
+// the request query. options may be nil.
+//
+// The proxy sets the `X-Forwarded-For`, `X-Forwarded-Host` and
+// `X-Forwarded-Proto` headers, see ProxyOptions.TrustForwardedHeaders,
+// and streams the request and response bodies.
+func NewProxy(target string, options *ProxyOptions) (*Proxy, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, fmt.Errorf("treemux: invalid proxy target %q: %w", target, err)
+	}
+	p := &Proxy{target: u}
+	if options != nil {
+		p.options = *options
+	}
+	if p.options.Pool == nil && (u.Scheme == "" || u.Host == "") {
+		return nil, fmt.Errorf("treemux: proxy target %q is not an absolute URL", target)
+	}
+	p.proxy = &httputil.ReverseProxy{
+		Director:       p.direct,
+		Transport:      p.options.Transport,
+		FlushInterval:  p.options.FlushInterval,
+		ModifyResponse: p.modifyResponse,
+		ErrorHandler:   p.handleError,
+	}
+	return p, nil
+}
+
+// Proxy registers proxy to serve requests of method and path.
+//
+// The path parameters to populate the target are the parameters
+// matched by the router.
+func (g *Group[T]) Proxy(method, path string, proxy *Proxy, opts ...RouteOption[T]) {
+	if g.mux.Bridge == nil {
+		panic("treemux: Bridge is not configured")
+	}
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxy.serve(w, r, requestParams(r))
+	})
+	opts = append(opts, func(opts *routeOptions[T]) {
+		opts.upstream = proxy.options.Pool
+		opts.passParams = true
+	})
+	g.Handle(method, path, g.mux.fromHTTPHandler(h), opts...)
+}
+
+// ServeHTTP implements [http.Handler], the path parameters are taken
+// from the request's ContextData.
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.serve(w, r, GetContextData(r).Params())
+}
+
+type proxyRequestKey struct{}
+
+// proxyRequest is the state of a proxied request.
+type proxyRequest struct {
+	target   *url.URL
+	failed   bool
+	canceled bool
+}
+
+func (p *Proxy) serve(w http.ResponseWriter, r *http.Request, params Params) {
+	preq := &proxyRequest{target: p.targetURL(r, params)}
+	if pool := p.options.Pool; pool != nil {
+		u, err := pool.pick(params)
+		if err != nil {
+			p.handleError(w, r, err)
+			return
+		}
+		preq.target.Scheme, preq.target.Host = u.url.Scheme, u.url.Host
+		pool.begin(u)
+		defer func() {
+			if preq.canceled {
+				pool.cancel(u)
+			} else {
+				pool.done(u, preq.failed)
+			}
+		}()
+	}
+
+	ctx := context.WithValue(r.Context(), proxyRequestKey{}, preq)
+	if p.options.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.options.Timeout)
+		defer cancel()
+	}
+	p.proxy.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// targetURL populates the target template for the request.
+func (p *Proxy) targetURL(r *http.Request, params Params) *url.URL {
+	u := *p.target
+	if u.Path == "" {
+		u.Path, u.RawPath = r.URL.Path, r.URL.RawPath
+	} else {
+		// Keep the escaped parameter values escaped, e.g. "a%2Fb" is
+		// not forwarded as two segments.
+		u.Path, u.RawPath = expandTargetPath(u.Path, params), escapeTargetPath(u.Path, params)
+	}
+	if len(p.options.Rewrites) > 0 {
+		escaped := u.EscapedPath()
+		for _, rewrite := range p.options.Rewrites {
+			escaped = rewrite(escaped)
+		}
+		if unescaped, err := unescape(escaped); err == nil {
+			u.Path, u.RawPath = unescaped, escaped
+		} else {
+			u.Path, u.RawPath = escaped, ""
+		}
+	}
+	switch {
+	case u.RawQuery == "":
+		u.RawQuery = r.URL.RawQuery
+	case r.URL.RawQuery != "":
+		u.RawQuery += "&" + r.URL.RawQuery
+	}
+	return &u
+}
+
+func expandTargetPath(path string, params Params) string {
+	if !strings.Contains(path, "/:") && !strings.Contains(path, "/*") {
+		return path
+	}
+	segments := strings.Split(path, "/")
+	for i, seg := range segments {
+		if len(seg) > 1 && (seg[0] == ':' || seg[0] == '*') {
+			segments[i] = strings.TrimPrefix(params.Get(seg[1:]), "/")
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+func (p *Proxy) direct(req *http.Request) {
+	target := req.Context().Value(proxyRequestKey{}).(*proxyRequest).target
+
+	if p.options.TrustForwardedHeaders {
+		if _, ok := req.Header["X-Forwarded-Host"]; !ok {
+			req.Header.Set("X-Forwarded-Host", req.Host)
+		}
+		if _, ok := req.Header["X-Forwarded-Proto"]; !ok {
+			req.Header.Set("X-Forwarded-Proto", getRequestScheme(req))
+		}
+	} else {
+		// httputil.ReverseProxy appends the client address.
+		req.Header.Del("X-Forwarded-For")
+		req.Header.Set("X-Forwarded-Host", req.Host)
+		req.Header.Set("X-Forwarded-Proto", getRequestScheme(req))
+	}
+	req.URL.Scheme = target.Scheme
+	req.URL.Host = target.Host
+	req.URL.Path = target.Path
+	req.URL.RawPath = target.RawPath
+	req.URL.RawQuery = target.RawQuery
+	if !p.options.PreserveHost {
+		req.Host = target.Host
+	}
+	if _, ok := req.Header["User-Agent"]; !ok {
+		// Explicitly disable the default User-Agent.
+		req.Header.Set("User-Agent", "")
+	}
+}
+
+func (p *Proxy) modifyResponse(resp *http.Response) error {
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		if preq, ok := resp.Request.Context().Value(proxyRequestKey{}).(*proxyRequest); ok {
+			preq.failed = true
+		}
+	}
+	if p.options.ModifyResponse != nil {
+		return p.options.ModifyResponse(resp)
+	}
+	return nil
+}
+
+func (p *Proxy) handleError(w http.ResponseWriter, r *http.Request, err error) {
+	if preq, ok := r.Context().Value(proxyRequestKey{}).(*proxyRequest); ok {
+		// A request canceled by the client tells nothing about the
+		// health of the upstream.
+		if errors.Is(err, context.Canceled) || errors.Is(r.Context().Err(), context.Canceled) {
+			preq.canceled = true
+		} else {
+			preq.failed = true
+		}
+	}
+	if p.options.ErrorHandler != nil {
+		p.options.ErrorHandler(w, r, err)
+		return
+	}
+	if errors.Is(err, ErrNoHealthyUpstream) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		w.WriteHeader(http.StatusGatewayTimeout)
+		return
+	}
+	w.WriteHeader(http.StatusBadGateway)
+}
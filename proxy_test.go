@@ -0,0 +1,162 @@
+package treemux
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProxy(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(200 * time.Millisecond)
+		}
+		body, _ := io.ReadAll(r.Body)
+		fmt.Fprintf(w, "%s %s?%s host=%s fwd=%s,%s body=%s",
+			r.Method, r.URL.Path, r.URL.RawQuery, r.Host,
+			r.Header.Get("X-Forwarded-Host"), r.Header.Get("X-Forwarded-Proto"), body)
+	}))
+	defer upstream.Close()
+
+	mustProxy := func(target string, options *ProxyOptions) *Proxy {
+		p, err := NewProxy(target, options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	rewrite, err := NewRewriteFunc("/legacy/:name", "/v2/:name")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router := New[HandlerFunc]()
+	router.Proxy("GET", "/users/:id", mustProxy(upstream.URL+"/internal/users/:id?src=gw", nil))
+	router.NewGroup("/files").Proxy("POST", "/*path", mustProxy(upstream.URL+"/store/*path", nil))
+	router.Proxy("GET", "/legacy/:name", mustProxy(upstream.URL, &ProxyOptions{
+		Rewrites:     []RewriteFunc{rewrite},
+		PreserveHost: true,
+	}))
+	router.Proxy("GET", "/slow", mustProxy(upstream.URL, &ProxyOptions{Timeout: 50 * time.Millisecond}))
+	router.Proxy("GET", "/down", mustProxy("http://127.0.0.1:1", nil))
+
+	host := strings.TrimPrefix(upstream.URL, "http://")
+	tests := []struct {
+		method, path, body string
+		wantCode           int
+		wantBody           string
+	}{
+		{"GET", "/users/42?x=1", "", 200, "GET /internal/users/42?src=gw&x=1 host=" + host + " fwd=example.com,http body="},
+		{"POST", "/files/a/b.txt", "data", 200, "POST /store/a/b.txt? host=" + host + " fwd=example.com,http body=data"},
+		{"GET", "/legacy/bob", "", 200, "GET /v2/bob? host=example.com fwd=example.com,http body="},
+		{"GET", "/slow", "", http.StatusGatewayTimeout, ""},
+		{"GET", "/down", "", http.StatusBadGateway, ""},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r, _ := newRequest(tt.method, tt.path, strings.NewReader(tt.body))
+		r.Host = "example.com"
+		router.ServeHTTP(w, r)
+		if w.Code != tt.wantCode || w.Body.String() != tt.wantBody {
+			t.Errorf("%s %s: got %d %q, want %d %q", tt.method, tt.path, w.Code, w.Body.String(), tt.wantCode, tt.wantBody)
+		}
+	}
+
+	if _, err := NewProxy("/relative", nil); err == nil {
+		t.Error("expected error for relative target")
+	}
+}
+
+func TestProxyEscapedParams(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.RequestURI)
+	}))
+	defer upstream.Close()
+
+	p, err := NewProxy(upstream.URL+"/internal/users/:id", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rewrite, err := NewRewriteFunc("/v1/users/:id", "/internal/v1/users/:id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rp, err := NewProxy(upstream.URL, &ProxyOptions{Rewrites: []RewriteFunc{rewrite}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := New[HandlerFunc]()
+	router.Proxy("GET", "/users/:id", p)
+	router.Proxy("GET", "/v1/users/:id", rp)
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/users/a%2Fb", "/internal/users/a%2Fb"},
+		{"/users/x%20y", "/internal/users/x%20y"},
+		{"/users/plain42", "/internal/users/plain42"},
+		{"/v1/users/a%2Fb", "/internal/v1/users/a%2Fb"},
+		{"/v1/users/x%20y", "/internal/v1/users/x%20y"},
+		{"/v1/users/plain42", "/internal/v1/users/plain42"},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r, _ := newRequest("GET", tt.path, nil)
+		router.ServeHTTP(w, r)
+		if w.Code != http.StatusOK || w.Body.String() != tt.want {
+			t.Errorf("%s: expected %q, got %d %q", tt.path, tt.want, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestProxyForwardedHeaders(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s|%s|%s", r.Header.Get("X-Forwarded-For"),
+			r.Header.Get("X-Forwarded-Host"), r.Header.Get("X-Forwarded-Proto"))
+	}))
+	defer upstream.Close()
+
+	router := New[HandlerFunc]()
+	for path, trust := range map[string]bool{"/direct": false, "/chained": true} {
+		p, err := NewProxy(upstream.URL, &ProxyOptions{TrustForwardedHeaders: trust})
+		if err != nil {
+			t.Fatal(err)
+		}
+		router.Proxy("GET", path, p)
+	}
+
+	tests := []struct {
+		path   string
+		header map[string]string
+		want   string
+	}{
+		{"/direct", nil, "192.0.2.1|example.com|http"},
+		{"/direct", map[string]string{
+			"X-Forwarded-For":   "10.0.0.1",
+			"X-Forwarded-Host":  "evil.com",
+			"X-Forwarded-Proto": "https",
+		}, "192.0.2.1|example.com|http"},
+		{"/chained", nil, "192.0.2.1|example.com|http"},
+		{"/chained", map[string]string{
+			"X-Forwarded-For":   "10.0.0.1",
+			"X-Forwarded-Host":  "api.example.com",
+			"X-Forwarded-Proto": "https",
+		}, "10.0.0.1, 192.0.2.1|api.example.com|https"},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", tt.path, nil)
+		for k, v := range tt.header {
+			r.Header.Set(k, v)
+		}
+		router.ServeHTTP(w, r)
+		if w.Code != http.StatusOK || w.Body.String() != tt.want {
+			t.Errorf("%s %v: expected %q, got %d %q", tt.path, tt.header, tt.want, w.Code, w.Body.String())
+		}
+	}
+}
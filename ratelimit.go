@@ -0,0 +1,318 @@
+package treemux
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimitAlgorithm is the algorithm of a rate limit.
+type RateLimitAlgorithm int
+
+const (
+	// TokenBucket refills Limit tokens every Window, and allows bursts
+	// up to RateLimit.Burst requests.
+	TokenBucket RateLimitAlgorithm = iota
+
+	// SlidingWindow allows Limit requests in any Window, the count of
+	// the previous window is weighted by its overlap with the sliding
+	// window.
+	SlidingWindow
+)
+
+// RateLimitMetadataKey is the route metadata key to configure a rate
+// limit by WithMetadata, the value must be a *RateLimit.
+// WithRateLimit takes precedence over the metadata.
+const RateLimitMetadataKey = "treemux.ratelimit"
+
+// RateLimit configures rate limiting of routes, see WithRateLimit and
+// Group.UseRateLimit.
+//
+// Requests are counted per route pattern, i.e. LookupResult.RoutePath,
+// thus "/users/1" and "/users/2" share the limit of "/users/:id".
+// KeyParam and KeyFunc further partition the counters.
+type RateLimit struct {
+	// Limit is the number of requests allowed per Window.
+	Limit int
+
+	// Window is the period of Limit, zero means one second.
+	Window time.Duration
+
+	// Burst is the size of the bucket of TokenBucket, zero means Limit.
+	Burst int
+
+	// Algorithm is the rate limiting algorithm, the default is TokenBucket.
+	Algorithm RateLimitAlgorithm
+
+	// KeyParam, if not empty, is the path parameter whose value partitions
+	// the counters, e.g. "id" limits each user of "/users/:id" separately.
+	KeyParam string
+
+	// KeyFunc, if not nil, returns the client key which partitions the
+	// counters, e.g. ClientIPKey. An empty key is shared by all requests
+	// without a key.
+	KeyFunc func(r *http.Request) string
+
+	// Store keeps the counters. If nil, a store in memory shared by
+	// the router is used.
+	Store RateLimitStore
+}
+
+// RateLimitResult is the result of taking a request from a rate limit.
+type RateLimitResult struct {
+	Allowed bool
+
+	// Limit is the quota of the current window.
+	Limit int
+
+	// Remaining is the number of requests remaining in the current window.
+	Remaining int
+
+	// Reset is the time until the quota is fully restored.
+	Reset time.Duration
+
+	// RetryAfter is the time to wait before the next request is
+	// allowed, it is set when Allowed is false.
+	RetryAfter time.Duration
+}
+
+// RateLimitStore keeps the counters of rate limits. It can be
+// implemented by a shared storage to limit the rate of a cluster.
+type RateLimitStore interface {
+	// Take takes a request from the counter key of limit.
+	// If an error is returned, the request is allowed.
+	Take(key string, limit *RateLimit, now time.Time) (RateLimitResult, error)
+}
+
+// ClientIPKey returns the host of the request's remote address,
+// it can be used as RateLimit.KeyFunc.
+func ClientIPKey(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// UseRateLimit sets the rate limit of the routes added to the group
+// after the call, each route pattern has its own counters.
+// WithRateLimit overrides it for a single route.
+func (g *Group[T]) UseRateLimit(limit *RateLimit) {
+	g.rateLimit = limit
+}
+
+// WithRateLimit returns a RouteOption which sets the rate limit of
+// a single route, it overrides the rate limit of the group.
+// A nil limit disables rate limiting of the route.
+//
+// Requests exceeding the limit are served by
+// Router.TooManyRequestsHandler, with the header `Retry-After`.
+// All responses of the route have the headers `RateLimit-Limit`,
+// `RateLimit-Remaining` and `RateLimit-Reset`.
+func WithRateLimit[T HandlerConstraint](limit *RateLimit) RouteOption[T] {
+	return func(opts *routeOptions[T]) {
+		opts.rateLimit = limit
+		opts.rateLimitSet = true
+	}
+}
+
+func defaultTooManyRequestsHandler(w http.ResponseWriter, _ *http.Request) {
+	w.WriteHeader(http.StatusTooManyRequests)
+}
+
+// rateLimitMiddleware returns an [http.Handler] middleware which limits
+// the rate of requests of the route pattern routePath.
+func (t *Router[T]) rateLimitMiddleware(routePath string, limit *RateLimit) HTTPHandlerMiddleware {
+	if limit.Limit <= 0 {
+		panic(fmt.Sprintf("treemux: rate limit of path %s must be positive", routePath))
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := routePath
+			if limit.KeyParam != "" {
+				key += "\x00" + requestParams(r).Get(limit.KeyParam)
+			}
+			if limit.KeyFunc != nil {
+				key += "\x00" + limit.KeyFunc(r)
+			}
+
+			store := limit.Store
+			if store == nil {
+				store = t.rateLimitStore()
+			}
+			result, err := store.Take(key, limit, time.Now())
+			if err != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h := w.Header()
+			h.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+			h.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+			if !result.Allowed {
+				h.Set("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+				t.TooManyRequestsHandler(w, r)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func (t *Router[T]) rateLimitStore() RateLimitStore {
+	t.rateStoreOnce.Do(func() {
+		t.rateStore = NewMemoryRateLimitStore()
+	})
+	return t.rateStore
+}
+
+func ceilSeconds(d time.Duration) int {
+	if d <= 0 {
+		return 0
+	}
+	return int((d + time.Second - 1) / time.Second)
+}
+
+// MemoryRateLimitStore is a RateLimitStore in memory.
+// Idle counters are removed periodically.
+type MemoryRateLimitStore struct {
+	mu       sync.Mutex
+	counters map[string]*rateCounter
+	takes    int
+}
+
+type rateCounter struct {
+	// TokenBucket
+	tokens float64
+	last   time.Time
+
+	// SlidingWindow
+	windowStart time.Time
+	prevCount   int
+	currCount   int
+
+	window time.Duration
+}
+
+// NewMemoryRateLimitStore creates a MemoryRateLimitStore.
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{counters: make(map[string]*rateCounter)}
+}
+
+// memoryStoreSweepInterval is the number of takes between sweeps of
+// idle counters.
+const memoryStoreSweepInterval = 1024
+
+// Take implements RateLimitStore.
+func (s *MemoryRateLimitStore) Take(key string, limit *RateLimit, now time.Time) (RateLimitResult, error) {
+	window := limit.Window
+	if window <= 0 {
+		window = time.Second
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.takes++; s.takes >= memoryStoreSweepInterval {
+		s.takes = 0
+		s.sweep(now)
+	}
+	c := s.counters[key]
+	if c == nil {
+		c = &rateCounter{window: window}
+		if limit.Algorithm == SlidingWindow {
+			c.windowStart = now
+		} else {
+			c.tokens = float64(burstOf(limit))
+			c.last = now
+		}
+		s.counters[key] = c
+	}
+	if limit.Algorithm == SlidingWindow {
+		return c.takeSlidingWindow(limit.Limit, window, now), nil
+	}
+	return c.takeTokenBucket(limit.Limit, burstOf(limit), window, now), nil
+}
+
+func burstOf(limit *RateLimit) int {
+	if limit.Burst > 0 {
+		return limit.Burst
+	}
+	return limit.Limit
+}
+
+// sweep removes the counters which are idle for more than two windows.
+func (s *MemoryRateLimitStore) sweep(now time.Time) {
+	for key, c := range s.counters {
+		last := c.last
+		if c.windowStart.After(last) {
+			last = c.windowStart
+		}
+		if now.Sub(last) > 2*c.window {
+			delete(s.counters, key)
+		}
+	}
+}
+
+func (c *rateCounter) takeTokenBucket(limit, burst int, window time.Duration, now time.Time) RateLimitResult {
+	interval := window / time.Duration(limit) // time to refill a token
+	if elapsed := now.Sub(c.last); elapsed > 0 {
+		c.tokens = math.Min(float64(burst), c.tokens+float64(elapsed)/float64(interval))
+		c.last = now
+	}
+	result := RateLimitResult{Limit: burst}
+	if c.tokens >= 1 {
+		c.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = time.Duration((1 - c.tokens) * float64(interval))
+	}
+	result.Remaining = int(c.tokens)
+	result.Reset = time.Duration((float64(burst) - c.tokens) * float64(interval))
+	return result
+}
+
+func (c *rateCounter) takeSlidingWindow(limit int, window time.Duration, now time.Time) RateLimitResult {
+	if elapsed := now.Sub(c.windowStart); elapsed >= window {
+		n := elapsed / window
+		if n == 1 {
+			c.prevCount = c.currCount
+		} else {
+			c.prevCount = 0
+		}
+		c.currCount = 0
+		c.windowStart = c.windowStart.Add(n * window)
+	}
+	elapsed := now.Sub(c.windowStart)
+	weight := 1 - float64(elapsed)/float64(window)
+	count := int(math.Ceil(float64(c.prevCount)*weight)) + c.currCount
+
+	result := RateLimitResult{Limit: limit}
+	if count < limit {
+		c.currCount++
+		count++
+		result.Allowed = true
+	} else if c.currCount >= limit || c.prevCount == 0 {
+		result.RetryAfter = window - elapsed
+	} else {
+		// Wait until enough requests of the previous window slide out.
+		need := float64(count-limit+1) / float64(c.prevCount)
+		result.RetryAfter = time.Duration(need * float64(window))
+		if result.RetryAfter > window-elapsed {
+			result.RetryAfter = window - elapsed
+		}
+	}
+	result.Remaining = limit - count
+	if result.Remaining < 0 {
+		result.Remaining = 0
+	}
+	result.Reset = window - elapsed
+	if c.currCount > 0 {
+		result.Reset += window
+	}
+	return result
+}
@@ -0,0 +1,164 @@
+package treemux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimit(t *testing.T) {
+	router := New[HandlerFunc]()
+	handler := func(w http.ResponseWriter, r *http.Request, params Params) {}
+	api := router.NewGroup("/api")
+	api.UseRateLimit(&RateLimit{Limit: 2, Window: time.Minute})
+	api.GET("/users/:id", handler)
+	api.GET("/items/:id", handler, WithRateLimit[HandlerFunc](&RateLimit{Limit: 1, Window: time.Minute, KeyParam: "id"}))
+	api.GET("/clients", handler, WithRateLimit[HandlerFunc](&RateLimit{Limit: 1, Window: time.Minute, KeyFunc: ClientIPKey}))
+	api.GET("/meta", handler, WithMetadata[HandlerFunc](RateLimitMetadataKey, &RateLimit{Limit: 3, Window: time.Minute}))
+	api.GET("/free", handler, WithRateLimit[HandlerFunc](nil))
+
+	serve := func(path, remoteAddr string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r, _ := newRequest("GET", path, nil)
+		r.RemoteAddr = remoteAddr
+		router.ServeHTTP(w, r)
+		return w
+	}
+
+	// Different parameter values share the limit of the pattern.
+	w := serve("/api/users/1", "")
+	if w.Code != http.StatusOK || w.Header().Get("RateLimit-Limit") != "2" || w.Header().Get("RateLimit-Remaining") != "1" {
+		t.Errorf("unexpected response %d %v", w.Code, w.Header())
+	}
+	serve("/api/users/2", "")
+	w = serve("/api/users/3", "")
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("expected 429, got %d", w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "30" {
+		t.Errorf("expected Retry-After 30, got %q", got)
+	}
+	if got := w.Header().Get("RateLimit-Remaining"); got != "0" {
+		t.Errorf("expected RateLimit-Remaining 0, got %q", got)
+	}
+
+	// KeyParam partitions the counters by parameter value.
+	if w := serve("/api/items/1", ""); w.Code != http.StatusOK {
+		t.Errorf("expected 200, got %d", w.Code)
+	}
+	if w := serve("/api/items/2", ""); w.Code != http.StatusOK {
+		t.Errorf("expected 200 for another parameter value, got %d", w.Code)
+	}
+	if w := serve("/api/items/1", ""); w.Code != http.StatusTooManyRequests {
+		t.Errorf("expected 429, got %d", w.Code)
+	}
+	if w := serve("/api/items/a%2Fb", ""); w.Code != http.StatusOK {
+		t.Errorf("expected 200 for an escaped parameter value, got %d", w.Code)
+	}
+	if w := serve("/api/items/c%2Fd", ""); w.Code != http.StatusOK {
+		t.Errorf("expected 200 for another escaped parameter value, got %d", w.Code)
+	}
+
+	// KeyFunc partitions the counters by client.
+	if w := serve("/api/clients", "10.0.0.1:1234"); w.Code != http.StatusOK {
+		t.Errorf("expected 200, got %d", w.Code)
+	}
+	if w := serve("/api/clients", "10.0.0.2:1234"); w.Code != http.StatusOK {
+		t.Errorf("expected 200 for another client, got %d", w.Code)
+	}
+	if w := serve("/api/clients", "10.0.0.1:5678"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("expected 429, got %d", w.Code)
+	}
+
+	if w := serve("/api/meta", ""); w.Header().Get("RateLimit-Limit") != "3" {
+		t.Errorf("expected the rate limit from metadata, got %v", w.Header())
+	}
+	for i := 0; i < 5; i++ {
+		if w := serve("/api/free", ""); w.Code != http.StatusOK || w.Header().Get("RateLimit-Limit") != "" {
+			t.Fatalf("expected no rate limit, got %d %v", w.Code, w.Header())
+		}
+	}
+
+	lr, _ := router.LookupByPath("GET", "/api/users/1", "/api/users/1")
+	if route := lr.Route(); route == nil || route.RateLimit == nil || route.RateLimit.Limit != 2 {
+		t.Errorf("expected the rate limit in route info")
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	store := NewMemoryRateLimitStore()
+	limit := &RateLimit{Limit: 10, Window: time.Second, Burst: 2}
+	now := time.Unix(1000, 0)
+
+	for i := 0; i < 2; i++ {
+		if res, _ := store.Take("k", limit, now); !res.Allowed {
+			t.Fatalf("expected burst request %d to be allowed", i)
+		}
+	}
+	res, _ := store.Take("k", limit, now)
+	if res.Allowed || res.RetryAfter != 100*time.Millisecond {
+		t.Errorf("expected to retry after 100ms, got %+v", res)
+	}
+	if res.Reset != 200*time.Millisecond || res.Limit != 2 {
+		t.Errorf("unexpected result %+v", res)
+	}
+
+	now = now.Add(100 * time.Millisecond)
+	if res, _ := store.Take("k", limit, now); !res.Allowed || res.Remaining != 0 {
+		t.Errorf("expected a refilled token, got %+v", res)
+	}
+	now = now.Add(time.Hour)
+	if res, _ := store.Take("k", limit, now); !res.Allowed || res.Remaining != 1 {
+		t.Errorf("expected the bucket to be capped by burst, got %+v", res)
+	}
+}
+
+func TestSlidingWindow(t *testing.T) {
+	store := NewMemoryRateLimitStore()
+	limit := &RateLimit{Limit: 4, Window: time.Second, Algorithm: SlidingWindow}
+	now := time.Unix(1000, 0)
+
+	for i := 0; i < 4; i++ {
+		if res, _ := store.Take("k", limit, now); !res.Allowed || res.Remaining != 3-i {
+			t.Fatalf("expected request %d to be allowed, got %+v", i, res)
+		}
+	}
+	res, _ := store.Take("k", limit, now)
+	if res.Allowed || res.RetryAfter != time.Second {
+		t.Errorf("expected to retry after 1s, got %+v", res)
+	}
+
+	// Half of the previous window overlaps the sliding window.
+	now = now.Add(1500 * time.Millisecond)
+	for i := 0; i < 2; i++ {
+		if res, _ := store.Take("k", limit, now); !res.Allowed {
+			t.Fatalf("expected request %d to be allowed, got %+v", i, res)
+		}
+	}
+	if res, _ := store.Take("k", limit, now); res.Allowed {
+		t.Errorf("expected the limit to be reached, got %+v", res)
+	}
+
+	now = now.Add(2 * time.Second)
+	if res, _ := store.Take("k", limit, now); !res.Allowed || res.Remaining != 3 {
+		t.Errorf("expected a fresh window, got %+v", res)
+	}
+}
+
+func TestMemoryRateLimitStoreSweep(t *testing.T) {
+	store := NewMemoryRateLimitStore()
+	limit := &RateLimit{Limit: 1, Window: time.Second}
+	now := time.Unix(1000, 0)
+	store.Take("idle", limit, now)
+	now = now.Add(time.Minute)
+	for i := 0; i < memoryStoreSweepInterval; i++ {
+		store.Take("busy", limit, now)
+	}
+	if _, ok := store.counters["idle"]; ok {
+		t.Error("expected the idle counter to be removed")
+	}
+	if _, ok := store.counters["busy"]; !ok {
+		t.Error("expected the busy counter to be kept")
+	}
+}
@@ -0,0 +1,96 @@
+package treemux
+
+import (
+	"bufio"
+	"encoding/hex"
+	"hash"
+	"net"
+	"net/http"
+)
+
+// responseRecorder records the status code of the response written to
+// the underlying ResponseWriter, and optionally the body or its hash.
+// If the underlying writer is nil, the response is discarded.
+type responseRecorder struct {
+	http.ResponseWriter
+	header     http.Header
+	statusCode int
+
+	// maxBodySize enables recording the body if it is positive,
+	// the body is dropped and overflow is set when it is exceeded.
+	maxBodySize int
+	body        []byte
+	overflow    bool
+
+	// hash, if not nil, hashes the body.
+	hash hash.Hash
+}
+
+func (w *responseRecorder) Header() http.Header {
+	if w.ResponseWriter != nil {
+		return w.ResponseWriter.Header()
+	}
+	if w.header == nil {
+		w.header = make(http.Header)
+	}
+	return w.header
+}
+
+func (w *responseRecorder) WriteHeader(statusCode int) {
+	if w.statusCode == 0 {
+		w.statusCode = statusCode
+	}
+	if w.ResponseWriter != nil {
+		w.ResponseWriter.WriteHeader(statusCode)
+	}
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	if w.statusCode == 0 {
+		w.statusCode = http.StatusOK
+	}
+	if w.maxBodySize > 0 && !w.overflow {
+		if len(w.body)+len(b) > w.maxBodySize {
+			w.overflow = true
+			w.body = nil
+		} else {
+			w.body = append(w.body, b...)
+		}
+	}
+	if w.hash != nil {
+		w.hash.Write(b)
+	}
+	if w.ResponseWriter != nil {
+		return w.ResponseWriter.Write(b)
+	}
+	return len(b), nil
+}
+
+func (w *responseRecorder) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *responseRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, http.ErrNotSupported
+}
+
+func (w *responseRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *responseRecorder) status() int {
+	if w.statusCode == 0 {
+		return http.StatusOK
+	}
+	return w.statusCode
+}
+
+// sum returns the hex encoded hash of the body.
+func (w *responseRecorder) sum() string {
+	return hex.EncodeToString(w.hash.Sum(nil))
+}
@@ -0,0 +1,333 @@
+package treemux
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// BulkheadPolicy limits the number of requests in flight of a route,
+// see WithBulkhead.
+type BulkheadPolicy struct {
+	// MaxConcurrent is the maximum number of requests in flight.
+	MaxConcurrent int
+
+	// QueueTimeout is how long a request waits for a slot when
+	// MaxConcurrent requests are in flight. Zero means requests are
+	// rejected immediately.
+	QueueTimeout time.Duration
+}
+
+// CircuitBreakerPolicy configures a circuit breaker, see
+// WithCircuitBreaker.
+type CircuitBreakerPolicy struct {
+	// FailureThreshold is the number of consecutive failures which
+	// opens the circuit. Zero means 5.
+	FailureThreshold int
+
+	// OpenDuration is how long the circuit stays open before it turns
+	// half-open. Zero means 30 seconds.
+	OpenDuration time.Duration
+
+	// HalfOpenRequests is the number of trial requests allowed in the
+	// half-open state, the circuit closes after all of them succeed.
+	// Zero means 1.
+	HalfOpenRequests int
+
+	// IsFailure tells whether a response status code is a failure.
+	// By default, status codes >= 500 are failures.
+	// A panic in the handler is always a failure.
+	IsFailure func(statusCode int) bool
+}
+
+// CircuitState is the state of a circuit breaker.
+type CircuitState int
+
+const (
+	// CircuitClosed means requests are served normally.
+	CircuitClosed CircuitState = iota
+
+	// CircuitOpen means requests are rejected.
+	CircuitOpen
+
+	// CircuitHalfOpen means a limited number of trial requests are
+	// served to decide whether to close the circuit.
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return "CircuitState(" + strconv.Itoa(int(s)) + ")"
+}
+
+// WithBulkhead returns a RouteOption which limits the requests in flight
+// of the route pattern. Routes of the same pattern share the limit, they
+// must have the same policy.
+// Rejected requests are served by Router.ServiceUnavailableHandler.
+func WithBulkhead[T HandlerConstraint](policy BulkheadPolicy) RouteOption[T] {
+	return func(opts *routeOptions[T]) {
+		opts.bulkhead = &policy
+	}
+}
+
+// WithCircuitBreaker returns a RouteOption which guards the route
+// pattern by a circuit breaker. Routes of the same pattern share the
+// breaker, they must be registered with the same RouteOption value.
+// When the circuit is open, requests are served by
+// Router.ServiceUnavailableHandler with a `Retry-After` header.
+//
+// Panics of the handler are counted as failures, and then propagated
+// to Router.PanicHandler.
+func WithCircuitBreaker[T HandlerConstraint](policy CircuitBreakerPolicy) RouteOption[T] {
+	return func(opts *routeOptions[T]) {
+		opts.breaker = &policy
+	}
+}
+
+// CircuitBreakers returns the states of the circuit breakers, keyed by
+// route pattern, i.e. LookupResult.RoutePath.
+func (t *Router[T]) CircuitBreakers() map[string]CircuitState {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	states := make(map[string]CircuitState, len(t.breakers))
+	for path, cb := range t.breakers {
+		states[path] = cb.currentState()
+	}
+	return states
+}
+
+// CircuitBreakerState returns the state of the circuit breaker of the
+// route pattern routePath, i.e. LookupResult.RoutePath. ok is false if the
+// pattern has no circuit breaker.
+func (t *Router[T]) CircuitBreakerState(routePath string) (state CircuitState, ok bool) {
+	t.mutex.RLock()
+	cb := t.breakers[routePath]
+	t.mutex.RUnlock()
+	if cb == nil {
+		return CircuitClosed, false
+	}
+	return cb.currentState(), true
+}
+
+func defaultServiceUnavailableHandler(w http.ResponseWriter, _ *http.Request) {
+	w.WriteHeader(http.StatusServiceUnavailable)
+}
+
+// resilienceMiddleware returns an [http.Handler] middleware which applies
+// the bulkhead and circuit breaker of the route pattern path.
+// The caller must hold t.mutex.
+func (t *Router[T]) resilienceMiddleware(path string, bh *BulkheadPolicy, cbp *CircuitBreakerPolicy) HTTPHandlerMiddleware {
+	// Routes of the same pattern share the bulkhead and the breaker,
+	// thus they must have the same policies.
+	var sem chan struct{}
+	var queueTimeout time.Duration
+	if bh != nil {
+		if bh.MaxConcurrent <= 0 {
+			panic(fmt.Sprintf("treemux: bulkhead MaxConcurrent of path %s must be positive", path))
+		}
+		if t.bulkheads == nil {
+			t.bulkheads = make(map[string]*bulkhead)
+		}
+		b := t.bulkheads[path]
+		if b == nil {
+			b = &bulkhead{policy: *bh, sem: make(chan struct{}, bh.MaxConcurrent)}
+			t.bulkheads[path] = b
+		} else if b.policy != *bh {
+			panic(fmt.Sprintf("treemux: conflicting bulkhead policies for path %s", path))
+		}
+		sem, queueTimeout = b.sem, bh.QueueTimeout
+	}
+	var cb *circuitBreaker
+	if cbp != nil {
+		if t.breakers == nil {
+			t.breakers = make(map[string]*circuitBreaker)
+		}
+		// IsFailure functions can not be compared, thus the policy
+		// is identified by the pointer held by the RouteOption.
+		if cb = t.breakers[path]; cb == nil {
+			cb = newCircuitBreaker(cbp)
+			t.breakers[path] = cb
+		} else if cb.source != cbp {
+			panic(fmt.Sprintf("treemux: conflicting circuit breaker policies for path %s", path))
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if sem != nil {
+				if !acquireSlot(r, sem, queueTimeout) {
+					t.ServiceUnavailableHandler(w, r)
+					return
+				}
+				defer func() { <-sem }()
+			}
+			if cb == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ok, gen, retryAfter := cb.allow()
+			if !ok {
+				w.Header().Set("Retry-After", strconv.Itoa(int((retryAfter+time.Second-1)/time.Second)))
+				t.ServiceUnavailableHandler(w, r)
+				return
+			}
+			sw := &responseRecorder{ResponseWriter: w}
+			panicked := true
+			defer func() {
+				cb.record(gen, panicked || cb.isFailure(sw.status()))
+			}()
+			next.ServeHTTP(sw, r)
+			panicked = false
+		})
+	}
+}
+
+func acquireSlot(r *http.Request, sem chan struct{}, timeout time.Duration) bool {
+	select {
+	case sem <- struct{}{}:
+		return true
+	default:
+	}
+	if timeout <= 0 {
+		return false
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case sem <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	case <-r.Context().Done():
+		return false
+	}
+}
+
+// bulkhead is the bulkhead of a route pattern.
+type bulkhead struct {
+	policy BulkheadPolicy
+	sem    chan struct{}
+}
+
+type circuitBreaker struct {
+	source *CircuitBreakerPolicy
+	policy CircuitBreakerPolicy
+
+	mu         sync.Mutex
+	state      CircuitState
+	generation uint64 // incremented on every state change
+	failures   int
+	openedAt   time.Time
+	trials     int // trial requests started in the half-open state
+	successes  int // trial requests succeeded in the half-open state
+}
+
+func newCircuitBreaker(source *CircuitBreakerPolicy) *circuitBreaker {
+	policy := *source
+	if policy.FailureThreshold <= 0 {
+		policy.FailureThreshold = 5
+	}
+	if policy.OpenDuration <= 0 {
+		policy.OpenDuration = 30 * time.Second
+	}
+	if policy.HalfOpenRequests <= 0 {
+		policy.HalfOpenRequests = 1
+	}
+	return &circuitBreaker{source: source, policy: policy}
+}
+
+func (cb *circuitBreaker) isFailure(statusCode int) bool {
+	if cb.policy.IsFailure != nil {
+		return cb.policy.IsFailure(statusCode)
+	}
+	return statusCode >= 500
+}
+
+// currentState returns the state, moving an expired open circuit
+// to half-open.
+func (cb *circuitBreaker) currentState() CircuitState {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.checkOpenLocked(time.Now())
+	return cb.state
+}
+
+func (cb *circuitBreaker) checkOpenLocked(now time.Time) {
+	if cb.state == CircuitOpen && now.Sub(cb.openedAt) >= cb.policy.OpenDuration {
+		cb.setStateLocked(CircuitHalfOpen)
+		cb.trials, cb.successes = 0, 0
+	}
+}
+
+func (cb *circuitBreaker) setStateLocked(state CircuitState) {
+	cb.state = state
+	cb.generation++
+}
+
+// allow tells whether a request is allowed, if so, it returns the
+// generation to pass to record, else the time to wait before the
+// circuit turns half-open.
+func (cb *circuitBreaker) allow() (bool, uint64, time.Duration) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	now := time.Now()
+	cb.checkOpenLocked(now)
+	switch cb.state {
+	case CircuitOpen:
+		return false, 0, cb.policy.OpenDuration - now.Sub(cb.openedAt)
+	case CircuitHalfOpen:
+		if cb.trials >= cb.policy.HalfOpenRequests {
+			return false, 0, time.Second
+		}
+		cb.trials++
+	}
+	return true, cb.generation, 0
+}
+
+// record records the result of a request allowed in generation gen.
+// Results of requests allowed before the last state change are ignored,
+// thus only the trial requests decide a half-open circuit.
+func (cb *circuitBreaker) record(gen uint64, failed bool) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if gen != cb.generation {
+		return
+	}
+	switch cb.state {
+	case CircuitClosed:
+		if !failed {
+			cb.failures = 0
+			return
+		}
+		cb.failures++
+		if cb.failures >= cb.policy.FailureThreshold {
+			cb.open()
+		}
+	case CircuitHalfOpen:
+		if failed {
+			cb.open()
+			return
+		}
+		cb.successes++
+		if cb.successes >= cb.policy.HalfOpenRequests {
+			cb.setStateLocked(CircuitClosed)
+			cb.failures = 0
+		}
+	}
+}
+
+func (cb *circuitBreaker) open() {
+	cb.setStateLocked(CircuitOpen)
+	cb.openedAt = time.Now()
+	cb.failures = 0
+}
@@ -0,0 +1,242 @@
+package treemux
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBulkhead(t *testing.T) {
+	started := make(chan struct{}, 2)
+	release := make(chan struct{})
+	router := New[HandlerFunc]()
+	router.ServiceUnavailableHandler = func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("busy"))
+	}
+	bulkhead := WithBulkhead[HandlerFunc](BulkheadPolicy{MaxConcurrent: 1, QueueTimeout: 20 * time.Millisecond})
+	router.GET("/slow/:id", func(w http.ResponseWriter, r *http.Request, params Params) {
+		started <- struct{}{}
+		<-release
+	}, bulkhead)
+	router.GET("/fast", func(w http.ResponseWriter, r *http.Request, params Params) {})
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r, _ := newRequest("GET", path, nil)
+		router.ServeHTTP(w, r)
+		return w
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if w := serve("/slow/1"); w.Code != http.StatusOK {
+			t.Errorf("expected 200 for the first request, got %d", w.Code)
+		}
+	}()
+	<-started
+
+	// Requests of the same pattern share the limit.
+	if w := serve("/slow/2"); w.Code != http.StatusServiceUnavailable || w.Body.String() != "busy" {
+		t.Errorf("expected the custom 503 response, got %d %q", w.Code, w.Body.String())
+	}
+	if w := serve("/fast"); w.Code != http.StatusOK {
+		t.Errorf("other routes must not be limited, got %d", w.Code)
+	}
+
+	release <- struct{}{}
+	wg.Wait()
+
+	// A queued request is served when a slot is released in time.
+	router.GET("/queue/:id", func(w http.ResponseWriter, r *http.Request, params Params) {
+		started <- struct{}{}
+		<-release
+	}, WithBulkhead[HandlerFunc](BulkheadPolicy{MaxConcurrent: 1, QueueTimeout: time.Second}))
+	results := make(chan int, 2)
+	for i := 0; i < 2; i++ {
+		go func() { results <- serve("/queue/1").Code }()
+	}
+	<-started
+	release <- struct{}{}
+	<-started
+	release <- struct{}{}
+	for i := 0; i < 2; i++ {
+		if code := <-results; code != http.StatusOK {
+			t.Errorf("expected the queued request to be served, got %d", code)
+		}
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	status := http.StatusOK
+	router := New[HandlerFunc]()
+	router.PanicHandler = SimplePanicHandler
+	router.GET("/users/:id", func(w http.ResponseWriter, r *http.Request, params Params) {
+		if params.Get("id") == "panic" {
+			panic("boom")
+		}
+		w.WriteHeader(status)
+	}, WithCircuitBreaker[HandlerFunc](CircuitBreakerPolicy{
+		FailureThreshold: 2,
+		OpenDuration:     50 * time.Millisecond,
+	}))
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r, _ := newRequest("GET", path, nil)
+		router.ServeHTTP(w, r)
+		return w
+	}
+	checkState := func(want CircuitState) {
+		t.Helper()
+		if state, ok := router.CircuitBreakerState("/users/:id"); !ok || state != want {
+			t.Fatalf("expected state %s, got %s (ok %v)", want, state, ok)
+		}
+	}
+
+	if _, ok := router.CircuitBreakerState("/other"); ok {
+		t.Error("expected no breaker for /other")
+	}
+
+	checkState(CircuitClosed)
+	status = http.StatusInternalServerError
+	serve("/users/1")
+	checkState(CircuitClosed)
+
+	// A panic is a failure, and still reaches PanicHandler.
+	if w := serve("/users/panic"); w.Code != http.StatusInternalServerError {
+		t.Errorf("expected the panic to be handled, got %d", w.Code)
+	}
+	checkState(CircuitOpen)
+
+	status = http.StatusOK
+	w := serve("/users/1")
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 when the circuit is open, got %d", w.Code)
+	}
+	if w.Header().Get("Retry-After") != "1" {
+		t.Errorf("expected Retry-After 1, got %q", w.Header().Get("Retry-After"))
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	checkState(CircuitHalfOpen)
+	if w := serve("/users/1"); w.Code != http.StatusOK {
+		t.Errorf("expected the trial request to be served, got %d", w.Code)
+	}
+	checkState(CircuitClosed)
+
+	if states := router.CircuitBreakers(); len(states) != 1 || states["/users/:id"] != CircuitClosed {
+		t.Errorf("unexpected breaker states %v", states)
+	}
+}
+
+func TestCircuitBreakerHalfOpenFailure(t *testing.T) {
+	cb := newCircuitBreaker(&CircuitBreakerPolicy{
+		FailureThreshold: 1,
+		OpenDuration:     time.Millisecond,
+		HalfOpenRequests: 2,
+		IsFailure:        func(code int) bool { return code == http.StatusTooManyRequests },
+	})
+	if !cb.isFailure(http.StatusTooManyRequests) || cb.isFailure(http.StatusInternalServerError) {
+		t.Error("IsFailure is not used")
+	}
+	_, gen, _ := cb.allow()
+	cb.record(gen, true)
+	time.Sleep(2 * time.Millisecond)
+
+	for i := 0; i < 2; i++ {
+		if ok, _, _ := cb.allow(); !ok {
+			t.Fatalf("expected trial request %d to be allowed", i)
+		}
+	}
+	if ok, _, _ := cb.allow(); ok {
+		t.Error("expected only 2 trial requests")
+	}
+	gen = cb.generation
+	cb.record(gen, false)
+	cb.record(gen, true)
+	if cb.state != CircuitOpen {
+		t.Errorf("expected a failed trial to reopen the circuit, got %s", cb.state)
+	}
+}
+
+func TestCircuitBreakerStaleResults(t *testing.T) {
+	cb := newCircuitBreaker(&CircuitBreakerPolicy{
+		FailureThreshold: 1,
+		OpenDuration:     time.Millisecond,
+	})
+	_, slow, _ := cb.allow()
+	_, gen, _ := cb.allow()
+	cb.record(gen, true)
+	time.Sleep(2 * time.Millisecond)
+	if state := cb.currentState(); state != CircuitHalfOpen {
+		t.Fatalf("expected half-open, got %s", state)
+	}
+
+	// A request allowed when the circuit was closed is not a trial.
+	cb.record(slow, false)
+	if cb.state != CircuitHalfOpen {
+		t.Errorf("expected a stale success to be ignored, got %s", cb.state)
+	}
+	ok, trial, _ := cb.allow()
+	if !ok {
+		t.Fatal("expected the trial request to be allowed")
+	}
+	cb.record(trial, false)
+	if cb.state != CircuitClosed {
+		t.Errorf("expected the trial to close the circuit, got %s", cb.state)
+	}
+}
+
+func TestResilienceConflictingPolicies(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request, params Params) {}
+	expectPanic := func(name string, fn func()) {
+		t.Helper()
+		defer func() {
+			if recover() == nil {
+				t.Errorf("%s: expected a panic for conflicting policies", name)
+			}
+		}()
+		fn()
+	}
+
+	router := New[HandlerFunc]()
+	router.GET("/a", handler, WithBulkhead[HandlerFunc](BulkheadPolicy{MaxConcurrent: 2}))
+	router.POST("/a", handler, WithBulkhead[HandlerFunc](BulkheadPolicy{MaxConcurrent: 2}))
+	expectPanic("bulkhead", func() {
+		router.PUT("/a", handler, WithBulkhead[HandlerFunc](BulkheadPolicy{MaxConcurrent: 3}))
+	})
+
+	breaker := WithCircuitBreaker[HandlerFunc](CircuitBreakerPolicy{FailureThreshold: 5})
+	router.GET("/b", handler, breaker)
+	router.POST("/b", handler, breaker)
+	expectPanic("breaker", func() {
+		router.PUT("/b", handler, WithCircuitBreaker[HandlerFunc](CircuitBreakerPolicy{FailureThreshold: 5}))
+	})
+
+	// Closures of the same code with different captured values are
+	// different policies.
+	isFailure := func(min int) func(int) bool {
+		return func(code int) bool { return code >= min }
+	}
+	router.GET("/c", handler, WithCircuitBreaker[HandlerFunc](CircuitBreakerPolicy{IsFailure: isFailure(500)}))
+	expectPanic("breaker IsFailure", func() {
+		router.POST("/c", handler, WithCircuitBreaker[HandlerFunc](CircuitBreakerPolicy{IsFailure: isFailure(400)}))
+	})
+}
+
+func TestBulkheadInvalidPolicy(t *testing.T) {
+	opt := WithBulkhead[HandlerFunc](BulkheadPolicy{})
+	defer func() {
+		if err := recover(); err == nil || !strings.Contains(fmt.Sprint(err), "/slow") {
+			t.Errorf("expected a panic naming the route, got %v", err)
+		}
+	}()
+	New[HandlerFunc]().GET("/slow", func(w http.ResponseWriter, r *http.Request, params Params) {}, opt)
+}
@@ -0,0 +1,390 @@
+package treemux
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RouteInfo contains the information of a registered route which does
+// not depend on the handler type.
+type RouteInfo struct {
+	// Method is the HTTP method the route is registered for.
+	Method string
+
+	// Path is the full pattern of the route, including the group prefix.
+	Path string
+
+	// RouteType is the type of the route's last path segment.
+	RouteType RouteType
+
+	// Metadata is arbitrary data attached to the route by WithMetadata.
+	Metadata map[string]any
+
+	// CORS is the CORS policy of the route, it is nil if CORS is not
+	// enabled for the route.
+	CORS *CORSPolicy
+
+	// Produces is the media types the route produces, see WithProduces.
+	Produces []string
+
+	// Consumes is the media types the route consumes, see WithConsumes.
+	Consumes []string
+
+	// Matchers is the request matchers which guard the route,
+	// see WithMatchers.
+	Matchers []RequestMatcher
+
+	// Version is the API version of the route, see Group.Version.
+	Version string
+
+	// VersionDeprecated tells whether the API version of the route
+	// is deprecated.
+	VersionDeprecated bool
+
+	// Deprecation is the deprecation of the route, see WithDeprecation.
+	Deprecation *Deprecation
+
+	// Variant is the name of the route in its traffic split,
+	// see WithSplit.
+	Variant string
+
+	// Upstream is the upstream pool of a proxy route, see Group.Proxy.
+	// Its statistics are available by UpstreamPool.Stats.
+	Upstream *UpstreamPool
+
+	// RateLimit is the rate limit of the route, see WithRateLimit.
+	RateLimit *RateLimit
+
+	// Cache is the response cache policy of the route, see WithCache.
+	Cache *CachePolicy
+
+	// Timeout is the timeout of the route, see Group.UseTimeout.
+	Timeout time.Duration
+
+	// MaxBodySize is the maximum size of request bodies of the route,
+	// see Group.UseMaxBodySize.
+	MaxBodySize int64
+
+	// Aliases is the full patterns of the aliases of the route,
+	// see WithAlias.
+	Aliases []string
+
+	// Name is the name of a localized route, see Group.HandleLocalized.
+	Name string
+
+	// Localized is the full patterns of a localized route by locale.
+	Localized map[string]string
+}
+
+// getRouteType tells the RouteType of a route pattern.
+func getRouteType(pattern string) RouteType {
+	routeType := Static
+	for _, segment := range strings.Split(pattern, "/") {
+		if segment == "" {
+			continue
+		}
+		switch segment[0] {
+		case ':':
+			routeType = Wildcard
+		case '~':
+			return Regexp
+		case '*':
+			return CatchAll
+		}
+	}
+	return routeType
+}
+
+// Route describes a route registered to a Router.
+type Route[T HandlerConstraint] struct {
+	RouteInfo
+
+	// Handler is the handler registered for the route, it's the handler
+	// before being wrapped by middlewares.
+	Handler T
+
+	// Middlewares is the ordered middleware chain which wraps Handler,
+	// the group-inherited middlewares come first, followed by the
+	// per-route middlewares.
+	Middlewares []Middleware[T]
+
+	// subRouter is set for the routes registered by Group.MountRouter.
+	subRouter *subRouter[T]
+
+	state *routeState
+
+	// passParams tells to pass the route data, including the path
+	// parameters, to the built-in http handlers and middlewares of the
+	// route, see requestRouteData.
+	passParams bool
+}
+
+// MiddlewareNames returns the names of the route's middleware chain.
+// Anonymous middlewares are reported by their function names.
+func (r *Route[T]) MiddlewareNames() []string {
+	names := make([]string, 0, len(r.Middlewares))
+	for _, mw := range r.Middlewares {
+		names = append(names, mw.String())
+	}
+	return names
+}
+
+// RouteOption configures a single route added by Group.Handle.
+type RouteOption[T HandlerConstraint] func(opts *routeOptions[T])
+
+type routeOptions[T HandlerConstraint] struct {
+	mux         *Router[T]
+	middlewares []Middleware[T]
+	excludes    map[string]bool
+	metadata    map[string]any
+	cors        *CORSPolicy
+	produces    []string
+	consumes    []string
+	matchers    []RequestMatcher
+
+	version           string
+	versionDeprecated bool
+	deprecation       *Deprecation
+
+	split   *TrafficSplit
+	variant string
+	mirror  *MirrorPolicy
+
+	upstream *UpstreamPool
+
+	rateLimit    *RateLimit
+	rateLimitSet bool
+
+	cache *CachePolicy
+
+	timeout     time.Duration
+	maxBodySize int64
+
+	aliases []Alias
+
+	passParams bool
+
+	name      string
+	localized map[string]string
+
+	bulkhead *BulkheadPolicy
+	breaker  *CircuitBreakerPolicy
+
+	subRouter *subRouter[T]
+}
+
+// WithMetadata returns a RouteOption which attaches a key value pair
+// to the route's metadata.
+func WithMetadata[T HandlerConstraint](key string, value any) RouteOption[T] {
+	return func(opts *routeOptions[T]) {
+		if opts.metadata == nil {
+			opts.metadata = make(map[string]any)
+		}
+		opts.metadata[key] = value
+	}
+}
+
+// WithMiddlewares returns a RouteOption which adds middlewares to a
+// single route, the middlewares run after the group's middlewares.
+func WithMiddlewares[T HandlerConstraint](middlewares ...MiddlewareFunc[T]) RouteOption[T] {
+	return func(opts *routeOptions[T]) {
+		opts.middlewares = append(opts.middlewares, toMiddlewares(middlewares)...)
+	}
+}
+
+// WithNamedMiddleware is like WithMiddlewares, but adds a named middleware.
+func WithNamedMiddleware[T HandlerConstraint](name string, middleware MiddlewareFunc[T]) RouteOption[T] {
+	return func(opts *routeOptions[T]) {
+		opts.middlewares = append(opts.middlewares, Middleware[T]{Name: name, Func: middleware})
+	}
+}
+
+// WithHTTPMiddlewares is like WithMiddlewares but accepts [http.Handler]
+// middlewares, which are converted by Router.Bridge.ConvertMiddleware.
+func WithHTTPMiddlewares[T HandlerConstraint](middlewares ...HTTPHandlerMiddleware) RouteOption[T] {
+	return func(opts *routeOptions[T]) {
+		if opts.mux.Bridge == nil {
+			panic("treemux: Bridge is not configured")
+		}
+		for _, mw := range middlewares {
+			mw := opts.mux.Bridge.ConvertMiddleware(mw)
+			opts.middlewares = append(opts.middlewares, Middleware[T]{Func: mw})
+		}
+	}
+}
+
+// WithoutMiddlewares returns a RouteOption which excludes the named
+// middlewares inherited from the group for a single route.
+func WithoutMiddlewares[T HandlerConstraint](names ...string) RouteOption[T] {
+	return func(opts *routeOptions[T]) {
+		if opts.excludes == nil {
+			opts.excludes = make(map[string]bool, len(names))
+		}
+		for _, name := range names {
+			opts.excludes[name] = true
+		}
+	}
+}
+
+// Routes returns all routes registered to the router, sorted by path
+// and method. Implicit HEAD routes added by HeadCanUseGet are not included.
+func (t *Router[T]) Routes() []*Route[T] {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	var routes []*Route[T]
+	seen := make(map[*Route[T]]bool)
+	add := func(route *Route[T]) {
+		if !seen[route] {
+			seen[route] = true
+			routes = append(routes, route)
+		}
+	}
+	mounted := make(map[*subRouter[T]]bool)
+	t.root.walk(func(n *node[T]) {
+		if sub := n.subRouter; sub != nil {
+			if !mounted[sub] {
+				mounted[sub] = true
+				for _, route := range sub.router.Routes() {
+					cp := *route
+					cp.Path = sub.prefix + route.Path
+					add(&cp)
+				}
+			}
+			return
+		}
+		for _, route := range n.leafRoutes {
+			add(route)
+		}
+		for _, set := range n.leafVariants {
+			for _, v := range set.variants {
+				add(v.route)
+			}
+		}
+	})
+	sort.SliceStable(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+	return routes
+}
+
+// RoutePredicate reports whether a route matches some conditions.
+// It is used by Group.UseIf to decide whether a middleware
+// should be applied to a route.
+type RoutePredicate func(info *RouteInfo) bool
+
+// MatchMethods returns a RoutePredicate which matches routes
+// registered for any of the given methods.
+func MatchMethods(methods ...string) RoutePredicate {
+	return func(info *RouteInfo) bool {
+		for _, m := range methods {
+			if m == info.Method {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// MatchPattern returns a RoutePredicate which matches routes whose
+// full pattern matches the glob.
+// In the glob, "*" matches any sequence of characters except "/",
+// and "**" matches any sequence of characters, e.g. "/api/**" matches
+// all routes under "/api/".
+func MatchPattern(glob string) RoutePredicate {
+	return func(info *RouteInfo) bool {
+		return matchGlob(glob, info.Path)
+	}
+}
+
+// MatchRouteType returns a RoutePredicate which matches routes of
+// any of the given types.
+func MatchRouteType(types ...RouteType) RoutePredicate {
+	return func(info *RouteInfo) bool {
+		for _, typ := range types {
+			if typ == info.RouteType {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// MatchMetadata returns a RoutePredicate which matches routes having
+// metadata key. If value is not nil, the metadata value must also
+// equal to value.
+func MatchMetadata(key string, value any) RoutePredicate {
+	return func(info *RouteInfo) bool {
+		x, ok := info.Metadata[key]
+		if !ok {
+			return false
+		}
+		return value == nil || reflect.DeepEqual(x, value)
+	}
+}
+
+// And returns a RoutePredicate which matches a route if all the
+// given predicates match it.
+func And(predicates ...RoutePredicate) RoutePredicate {
+	return func(info *RouteInfo) bool {
+		for _, p := range predicates {
+			if !p(info) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Or returns a RoutePredicate which matches a route if any of the
+// given predicates matches it.
+func Or(predicates ...RoutePredicate) RoutePredicate {
+	return func(info *RouteInfo) bool {
+		for _, p := range predicates {
+			if p(info) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Not returns a RoutePredicate which negates predicate.
+func Not(predicate RoutePredicate) RoutePredicate {
+	return func(info *RouteInfo) bool {
+		return !predicate(info)
+	}
+}
+
+func matchGlob(glob, s string) bool {
+	for len(glob) > 0 {
+		if glob[0] != '*' {
+			if len(s) == 0 || s[0] != glob[0] {
+				return false
+			}
+			glob, s = glob[1:], s[1:]
+			continue
+		}
+		crossSlash := strings.HasPrefix(glob, "**")
+		if crossSlash {
+			glob = glob[2:]
+		} else {
+			glob = glob[1:]
+		}
+		for i := 0; i <= len(s); i++ {
+			if matchGlob(glob, s[i:]) {
+				return true
+			}
+			if i < len(s) && s[i] == '/' && !crossSlash {
+				break
+			}
+		}
+		return false
+	}
+	return len(s) == 0
+}
@@ -0,0 +1,197 @@
+package treemux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// execLog records the handlers and middlewares executed by a request.
+type execLog []string
+
+func (l *execLog) handler(name string) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, params Params) {
+		*l = append(*l, name)
+	}
+}
+
+func (l *execLog) middleware(name string) MiddlewareFunc[HandlerFunc] {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request, params Params) {
+			*l = append(*l, name)
+			next(w, r, params)
+		}
+	}
+}
+
+func (l *execLog) httpMiddleware(name string) HTTPHandlerMiddleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			*l = append(*l, name)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// serve serves a request by router and returns the executed names.
+func (l *execLog) serve(router *Router[HandlerFunc], method, path string) []string {
+	*l = nil
+	w := httptest.NewRecorder()
+	r, _ := newRequest(method, path, nil)
+	router.ServeHTTP(w, r)
+	return *l
+}
+
+func TestPerRouteMiddleware(t *testing.T) {
+	var log execLog
+
+	router := New[HandlerFunc]()
+	router.Use(log.middleware("m1"))
+	router.With(log.middleware("m2")).GET("/h1", log.handler("h1"))
+	router.WithHandler(log.httpMiddleware("m3")).GET("/h2", log.handler("h2"))
+	router.GET("/h3", log.handler("h3"),
+		WithMiddlewares(log.middleware("m4")),
+		WithHTTPMiddlewares[HandlerFunc](log.httpMiddleware("m5")))
+	router.GET("/h4", log.handler("h4"))
+
+	tests := []struct {
+		path     string
+		want     []string
+		chainLen int
+	}{
+		{"/h1", []string{"m1", "m2", "h1"}, 2},
+		{"/h2", []string{"m1", "m3", "h2"}, 2},
+		{"/h3", []string{"m1", "m4", "m5", "h3"}, 3},
+		{"/h4", []string{"m1", "h4"}, 1},
+	}
+	for _, tt := range tests {
+		if got := log.serve(router, "GET", tt.path); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, wanted %v", tt.path, got, tt.want)
+		}
+	}
+
+	routes := router.Routes()
+	if len(routes) != len(tests) {
+		t.Fatalf("expected %d routes, got %d", len(tests), len(routes))
+	}
+	for i, route := range routes {
+		tt := tests[i]
+		if route.Method != "GET" || route.Path != tt.path {
+			t.Errorf("unexpected route %s %s, wanted GET %s", route.Method, route.Path, tt.path)
+		}
+		if got := len(route.Middlewares); got != tt.chainLen {
+			t.Errorf("%s: expected %d middlewares, got %d", route.Path, tt.chainLen, got)
+		}
+	}
+}
+
+func TestNamedMiddleware(t *testing.T) {
+	var log execLog
+
+	router := New[HandlerFunc]()
+	router.UseNamed("auth", log.middleware("auth"))
+	router.UseNamed("logging", log.middleware("logging"))
+	api := router.NewGroup("/api")
+	api.Use(log.middleware("anonymous"))
+	api.GET("/users", log.handler("users"), WithNamedMiddleware("cache", log.middleware("cache")))
+	api.GET("/health", log.handler("health"), WithoutMiddlewares[HandlerFunc]("auth"))
+
+	// Anonymous middlewares are named by their functions.
+	const anonymous = "github.com/jxskiss/treemux.(*execLog).middleware"
+
+	tests := []struct {
+		path      string
+		wantNames []string
+		want      []string
+	}{
+		{"/api/users", []string{"auth", "logging", anonymous, "cache"}, []string{"auth", "logging", "anonymous", "cache", "users"}},
+		{"/api/health", []string{"logging", anonymous}, []string{"logging", "anonymous", "health"}},
+	}
+	routes := make(map[string]*Route[HandlerFunc])
+	for _, route := range router.Routes() {
+		routes[route.Path] = route
+	}
+	for _, tt := range tests {
+		route := routes[tt.path]
+		if route == nil {
+			t.Fatalf("%s: route not found", tt.path)
+		}
+		names := route.MiddlewareNames()
+		if len(names) != len(tt.wantNames) {
+			t.Errorf("%s: unexpected middleware names %v", tt.path, names)
+			continue
+		}
+		for i, name := range names {
+			if name != tt.wantNames[i] && !(tt.wantNames[i] == anonymous && strings.HasPrefix(name, anonymous)) {
+				t.Errorf("%s: unexpected middleware names %v", tt.path, names)
+				break
+			}
+		}
+		if got := log.serve(router, "GET", tt.path); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, wanted %v", tt.path, got, tt.want)
+		}
+	}
+
+	dump := router.Dump()
+	if !strings.Contains(dump, "GET middlewares [auth logging") {
+		t.Errorf("middleware chain not found in dump:\n%s", dump)
+	}
+}
+
+func TestConditionalMiddleware(t *testing.T) {
+	var log execLog
+
+	router := New[HandlerFunc]()
+	router.UseNamedIf("ratelimit", And(
+		MatchMethods("POST"),
+		MatchPattern("/api/**"),
+		Not(MatchPattern("/api/health")),
+	), log.middleware("ratelimit"))
+	router.UseIf(MatchMetadata("auth", true), log.middleware("auth"))
+
+	router.POST("/api/users", simpleHandler, WithMetadata[HandlerFunc]("auth", true))
+	router.POST("/api/users/:id/avatar", simpleHandler)
+	router.GET("/api/users", simpleHandler)
+	router.POST("/api/health", simpleHandler)
+	router.POST("/login", simpleHandler)
+
+	tests := []struct {
+		method, path string
+		want         []string
+	}{
+		{"POST", "/api/users", []string{"ratelimit", "auth"}},
+		{"POST", "/api/users/1/avatar", []string{"ratelimit"}},
+		{"GET", "/api/users", nil},
+		{"POST", "/api/health", nil},
+		{"POST", "/login", nil},
+	}
+	for _, tt := range tests {
+		if got := log.serve(router, tt.method, tt.path); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s %s: got %v, wanted %v", tt.method, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		glob, path string
+		want       bool
+	}{
+		{"/api/*", "/api/users", true},
+		{"/api/*", "/api/users/:id", false},
+		{"/api/**", "/api/users/:id", true},
+		{"/api/*/avatar", "/api/users/avatar", true},
+		{"/api/**/avatar", "/api/users/:id/avatar", true},
+		{"/api/health", "/api/health", true},
+		{"/api/health", "/api/healthz", false},
+		{"*", "/", false},
+		{"**", "/", true},
+	}
+	for _, tt := range tests {
+		if got := matchGlob(tt.glob, tt.path); got != tt.want {
+			t.Errorf("matchGlob(%q, %q) = %v, wanted %v", tt.glob, tt.path, got, tt.want)
+		}
+	}
+}
@@ -75,8 +75,14 @@ type LookupResult[T HandlerConstraint] struct {
 	StatusCode int
 
 	// Non-empty RedirectPath indicates that the request should be redirected.
+	// It is an unescaped path, unless RedirectEscaped is true.
 	RedirectPath string
 
+	// RedirectEscaped tells that RedirectPath is already escaped,
+	// which is the case for the redirects of route aliases, see WithAlias.
+	// Callers must not escape it again.
+	RedirectEscaped bool
+
 	// When StatusCode is `http.StatusMethodNotAllowed`, AllowedMethods contains the
 	// methods registered for the request path, else it is nil.
 	AllowedMethods []string
@@ -93,6 +99,46 @@ type LookupResult[T HandlerConstraint] struct {
 	// When StatusCode is not `http.StatusNotFound`, RouteType is the type
 	// of the matched route.
 	RouteType RouteType
+
+	// Version is the API version of the matched route, see Group.Version.
+	Version string
+
+	// Variant is the traffic split variant of the matched route,
+	// see WithSplit.
+	Variant string
+
+	// Locale is the locale of the request, see Group.HandleLocalized
+	// and Router.LocaleExtractor.
+	Locale string
+
+	// MediaType is the media type selected by content negotiation,
+	// it is empty if the matched route does not declare media types
+	// it produces.
+	MediaType string
+
+	// Vary is the request headers which selected the handler by content
+	// negotiation, they are added to the `Vary` response header.
+	Vary []string
+
+	// ImplicitHead tells that the request is a HEAD request served by
+	// the GET handler, because of Router.HeadCanUseGet.
+	ImplicitHead bool
+
+	// node is the matched node, it is nil if no node matches.
+	node *node[T]
+
+	// route is the matched route.
+	route *Route[T]
+
+	// router is the sub-router which resolved the lookup, it is nil if
+	// the lookup is resolved by the router itself.
+	router *Router[T]
+}
+
+// Route returns the matched route, it returns nil if no route matches
+// or the request is served by Router.OptionsHandler.
+func (lr LookupResult[T]) Route() *Route[T] {
+	return lr.route
 }
 
 // Router is a generic HTTP request router.
@@ -101,6 +147,18 @@ type LookupResult[T HandlerConstraint] struct {
 type Router[T HandlerConstraint] struct {
 	root  *node[T]
 	mutex sync.RWMutex
+	pools map[string]*UpstreamPool
+
+	bulkheads map[string]*bulkhead
+	breakers  map[string]*circuitBreaker
+
+	rateStoreOnce sync.Once
+	rateStore     RateLimitStore
+
+	caches     map[string]*routeCache
+	cacheStore CacheStore
+
+	localized map[string]map[string]string
 
 	Group[T]
 
@@ -123,6 +181,48 @@ type Router[T HandlerConstraint] struct {
 	// http.StatusMethodNotAllowed and adds the required "Allow" header.
 	MethodNotAllowedHandler MethodNotAllowedHandler
 
+	// NotAcceptableHandler is called when a pattern matches, but none
+	// of the handlers produces a media type acceptable by the request.
+	// The default handler just writes the status code http.StatusNotAcceptable.
+	NotAcceptableHandler http.HandlerFunc
+
+	// UnsupportedMediaTypeHandler is called when a pattern matches, but
+	// none of the handlers consumes the request's content type.
+	// The default handler just writes the status code
+	// http.StatusUnsupportedMediaType.
+	UnsupportedMediaTypeHandler http.HandlerFunc
+
+	// ServiceUnavailableHandler is called when a request is rejected by
+	// the bulkhead or the open circuit breaker of a route, see
+	// WithBulkhead and WithCircuitBreaker.
+	// The default handler just writes the status code
+	// http.StatusServiceUnavailable.
+	ServiceUnavailableHandler http.HandlerFunc
+
+	// TooManyRequestsHandler is called when a request exceeds the rate
+	// limit of a route, see WithRateLimit.
+	// The default handler just writes the status code
+	// http.StatusTooManyRequests.
+	TooManyRequestsHandler http.HandlerFunc
+
+	// VersionExtractor extracts the requested API version from requests,
+	// it is used to select handlers registered with API versions.
+	// The default value is DefaultVersionExtractor.
+	VersionExtractor VersionExtractor
+
+	// LocaleExtractor extracts the locale from requests, it selects the
+	// locale of localized routes whose pattern is shared by several
+	// locales, and the locale of the other routes.
+	// It is nil by default, see LocaleFromPathPrefix, LocaleFromHost
+	// and LocaleFromAcceptLanguage.
+	LocaleExtractor LocaleExtractor
+
+	// DefaultLocale is the locale of requests whose locale can not be
+	// extracted. It selects the canonical patterns of localized routes,
+	// thus it must be set before they are registered, see
+	// Group.HandleLocalized.
+	DefaultLocale string
+
 	// HeadCanUseGet allows the router to use the GET handler to respond to
 	// HEAD requests if no explicit HEAD handler has been added for the
 	// matching pattern. This is true by default.
@@ -220,7 +320,7 @@ func (t *Router[_]) redirectStatusCode(method string) (int, bool) {
 	}
 }
 
-func (t *Router[T]) lookup(method, requestURI, urlPath string) (result LookupResult[T], found bool) {
+func (t *Router[T]) lookup(method, requestURI, urlPath string, r *http.Request) (result LookupResult[T], found bool) {
 
 	result.StatusCode = http.StatusNotFound
 
@@ -266,8 +366,9 @@ func (t *Router[T]) lookup(method, requestURI, urlPath string) (result LookupRes
 				// Redirect to the actual path
 				result.StatusCode = statusCode
 				result.RedirectPath = cleanPath
-				result.RoutePath = n.fullPath
+				result.RoutePath = n.routePath(method)
 				result.RouteType = n.routeType
+				result.node = n
 				found = true
 				return
 			}
@@ -277,6 +378,10 @@ func (t *Router[T]) lookup(method, requestURI, urlPath string) (result LookupRes
 		}
 	}
 
+	if n.subRouter != nil {
+		return t.lookupSubRouter(n, params, method, requestURI, urlPath, r)
+	}
+
 	if !isValid(handler) {
 		if method == "OPTIONS" && isValid(t.OptionsHandler) {
 			handler = t.OptionsHandler
@@ -284,9 +389,10 @@ func (t *Router[T]) lookup(method, requestURI, urlPath string) (result LookupRes
 
 		if !isValid(handler) {
 			result.StatusCode = http.StatusMethodNotAllowed
-			result.AllowedMethods = getSortedKeys(n.leafHandlers)
-			result.RoutePath = n.fullPath
+			result.AllowedMethods = n.allowedMethods()
+			result.RoutePath = n.routePath(method)
 			result.RouteType = n.routeType
+			result.node = n
 			return
 		}
 	}
@@ -297,15 +403,16 @@ func (t *Router[T]) lookup(method, requestURI, urlPath string) (result LookupRes
 				if n.addSlash {
 					result.StatusCode = statusCode
 					result.RedirectPath = unescapedPath + "/"
-					result.RoutePath = n.fullPath
+					result.RoutePath = n.routePath(method)
 					result.RouteType = n.routeType
 				} else if path != "/" {
 					result.StatusCode = statusCode
 					result.RedirectPath = unescapedPath
-					result.RoutePath = n.fullPath
+					result.RoutePath = n.routePath(method)
 					result.RouteType = n.routeType
 				}
 				if result.RedirectPath != "" {
+					result.node = n
 					found = true
 					return
 				}
@@ -325,13 +432,62 @@ func (t *Router[T]) lookup(method, requestURI, urlPath string) (result LookupRes
 		retParams.Keys = n.leafParamNames
 		retParams.Values = params
 	}
+	if alias := n.leafAliases[n.leafMethod(method)]; alias != nil {
+		if len(retParams.Keys) > 0 {
+			retParams.Keys = alias.keys
+		}
+		if alias.redirect {
+			if statusCode, ok := t.redirectStatusCode(method); ok {
+				result.StatusCode = statusCode
+				result.RedirectPath = escapeTargetPath(alias.path, retParams)
+				result.RedirectEscaped = true
+				result.RoutePath = alias.path
+				result.RouteType = n.routeType
+				result.node = n
+				found = true
+				return
+			}
+		}
+	}
 
 	result = LookupResult[T]{
-		StatusCode: http.StatusOK,
-		Params:     retParams,
-		Handler:    handler,
-		RoutePath:  n.fullPath,
-		RouteType:  n.routeType,
+		StatusCode:   http.StatusOK,
+		Params:       retParams,
+		Handler:      handler,
+		RoutePath:    n.routePath(method),
+		RouteType:    n.routeType,
+		ImplicitHead: method == "HEAD" && n.implicitHead,
+		node:         n,
+		route:        n.getRoute(method),
+	}
+	if n.leafVariants != nil {
+		if vr, ok := n.selectVariant(method, r, t.VersionExtractor); ok {
+			result.Handler = vr.handler
+			result.MediaType = vr.mediaType
+			result.Vary = vr.vary
+			result.route = vr.route
+			if vr.statusCode != 0 && !isValid(vr.handler) {
+				result.StatusCode = vr.statusCode
+				result.AllowedMethods = vr.allowedMethods
+				return
+			}
+		}
+	}
+	if result.route != nil {
+		result.Version = result.route.Version
+		result.Variant = result.route.Variant
+	}
+	if locales := n.leafLocales[n.leafMethod(method)]; locales != nil {
+		result.Locale = t.selectLocale(r, locales)
+		if result.Params.Get(LocaleParam) == "" {
+			result.Params.Keys = append(result.Params.Keys[:len(result.Params.Keys):len(result.Params.Keys)], LocaleParam)
+			result.Params.Values = append(result.Params.Values, result.Locale)
+		}
+	} else if t.LocaleExtractor != nil && r != nil {
+		result.Locale = t.LocaleExtractor(r)
+	}
+	if result.Locale == "" {
+		result.Locale = t.DefaultLocale
 	}
 	found = true
 	return
@@ -353,16 +509,18 @@ func (t *Router[T]) Lookup(w http.ResponseWriter, r *http.Request) (LookupResult
 	method := r.Method
 	requestURI := r.RequestURI
 	urlPath := r.URL.Path
-	return t.lookup(method, requestURI, urlPath)
+	return t.lookup(method, requestURI, urlPath, r)
 }
 
 // LookupByPath is similar to Lookup, except that it accepts the routing parameters directly.
+// When several handlers are registered for the same method and pattern, they are selected
+// as if the request has no headers.
 func (t *Router[T]) LookupByPath(method, requestURI, urlPath string) (LookupResult[T], bool) {
 	if t.SafeAddRoutesWhileRunning {
 		t.mutex.RLock()
 		defer t.mutex.RUnlock()
 	}
-	return t.lookup(method, requestURI, urlPath)
+	return t.lookup(method, requestURI, urlPath, nil)
 }
 
 // defaultMethodNotAllowedHandler is the default handler for Router.MethodNotAllowedHandler,
@@ -379,16 +537,21 @@ func defaultMethodNotAllowedHandler(
 // New creates a new Router[T].
 func New[T HandlerConstraint]() *Router[T] {
 	tm := &Router[T]{
-		root:                    &node[T]{path: "/"},
-		NotFoundHandler:         http.NotFound,
-		MethodNotAllowedHandler: defaultMethodNotAllowedHandler,
-		HeadCanUseGet:           true,
-		RedirectTrailingSlash:   true,
-		RedirectCleanPath:       true,
-		RedirectBehavior:        Redirect301,
-		RedirectMethodBehavior:  make(map[string]RedirectBehavior),
-		PathSource:              RequestURI,
-		EscapeAddedRoutes:       false,
+		root:                        &node[T]{path: "/"},
+		NotFoundHandler:             http.NotFound,
+		MethodNotAllowedHandler:     defaultMethodNotAllowedHandler,
+		NotAcceptableHandler:        defaultNotAcceptableHandler,
+		UnsupportedMediaTypeHandler: defaultUnsupportedMediaTypeHandler,
+		ServiceUnavailableHandler:   defaultServiceUnavailableHandler,
+		TooManyRequestsHandler:      defaultTooManyRequestsHandler,
+		VersionExtractor:            DefaultVersionExtractor,
+		HeadCanUseGet:               true,
+		RedirectTrailingSlash:       true,
+		RedirectCleanPath:           true,
+		RedirectBehavior:            Redirect301,
+		RedirectMethodBehavior:      make(map[string]RedirectBehavior),
+		PathSource:                  RequestURI,
+		EscapeAddedRoutes:           false,
 	}
 	tm.Group.mux = tm
 	setDefaultBridgeFunctions(tm)
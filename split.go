@@ -0,0 +1,186 @@
+package treemux
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
+
+// SplitKeyFunc returns the key to assign a request to a traffic split
+// variant, requests with the same key are assigned to the same variant
+// as long as the weights don't change. An empty key means the request
+// is assigned randomly.
+type SplitKeyFunc func(r *http.Request) string
+
+// SplitByHeader returns a SplitKeyFunc which uses the request header key.
+func SplitByHeader(key string) SplitKeyFunc {
+	return func(r *http.Request) string {
+		return r.Header.Get(key)
+	}
+}
+
+// SplitByCookie returns a SplitKeyFunc which uses the value of the
+// cookie name.
+func SplitByCookie(name string) SplitKeyFunc {
+	return func(r *http.Request) string {
+		if c, err := r.Cookie(name); err == nil {
+			return c.Value
+		}
+		return ""
+	}
+}
+
+// TrafficSplit splits the requests of a route between several handlers
+// by weights, e.g. to send 5% of the traffic to a canary implementation.
+//
+// Handlers are added to a split by WithSplit. The weights can be
+// changed by SetWeight at runtime, without rebuilding the routing tree.
+// A TrafficSplit must be created by NewTrafficSplit.
+type TrafficSplit struct {
+	key SplitKeyFunc
+
+	mu    sync.Mutex   // serializes writers
+	table atomic.Value // *splitTable
+}
+
+type splitTable struct {
+	names   []string
+	weights []int
+	total   int
+}
+
+// NewTrafficSplit creates a TrafficSplit. key decides the variant of a
+// request by deterministic hashing, if key is nil, requests are assigned
+// randomly.
+func NewTrafficSplit(key SplitKeyFunc) *TrafficSplit {
+	s := &TrafficSplit{key: key}
+	s.table.Store(&splitTable{})
+	return s
+}
+
+// SetWeight sets the weight of the variant name, a zero weight disables
+// the variant. It panics if no handler is registered with the name.
+func (s *TrafficSplit) SetWeight(name string, weight int) {
+	s.setWeight(name, weight, false)
+}
+
+// Weights returns the current weights of the variants.
+func (s *TrafficSplit) Weights() map[string]int {
+	table := s.table.Load().(*splitTable)
+	weights := make(map[string]int, len(table.names))
+	for i, name := range table.names {
+		weights[name] = table.weights[i]
+	}
+	return weights
+}
+
+// setWeight replaces the table with a copy which has the new weight,
+// if add is true, the variant is added if it does not exist.
+func (s *TrafficSplit) setWeight(name string, weight int, add bool) {
+	if weight < 0 {
+		panic(fmt.Sprintf("treemux: negative weight %d for split variant %q", weight, name))
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old := s.table.Load().(*splitTable)
+	table := &splitTable{
+		names:   append([]string(nil), old.names...),
+		weights: append([]int(nil), old.weights...),
+		total:   old.total,
+	}
+	i := indexOfString(table.names, name)
+	if i < 0 {
+		if !add {
+			panic(fmt.Sprintf("treemux: split variant %q is not registered", name))
+		}
+		i = len(table.names)
+		table.names = append(table.names, name)
+		table.weights = append(table.weights, 0)
+	}
+	table.total += weight - table.weights[i]
+	table.weights[i] = weight
+	s.table.Store(table)
+}
+
+// choose returns the variant name for the request, it returns an empty
+// string if all weights are zero.
+func (s *TrafficSplit) choose(r *http.Request) string {
+	table := s.table.Load().(*splitTable)
+	if table.total == 0 {
+		return ""
+	}
+	var n uint64
+	key := ""
+	if s.key != nil {
+		key = s.key(r)
+	}
+	if key != "" {
+		h := fnv.New64a()
+		h.Write([]byte(key))
+		n = h.Sum64()
+	} else {
+		n = rand.Uint64()
+	}
+	n %= uint64(table.total)
+	for i, w := range table.weights {
+		if n < uint64(w) {
+			return table.names[i]
+		}
+		n -= uint64(w)
+	}
+	return ""
+}
+
+// WithSplit returns a RouteOption which registers the handler as the
+// variant name of split, with the initial weight.
+//
+// Several handlers can be registered for the same method and pattern
+// with the same split, each request is served by one of them chosen by
+// the weights. The chosen variant is reported by LookupResult.Variant
+// and RouteData.Variant.
+// If all weights are zero, the first registered variant serves requests.
+func WithSplit[T HandlerConstraint](split *TrafficSplit, name string, weight int) RouteOption[T] {
+	return func(opts *routeOptions[T]) {
+		split.setWeight(name, weight, true)
+		opts.split = split
+		opts.variant = name
+	}
+}
+
+// filterBySplit returns the variants chosen by traffic splitting,
+// variants without a split are kept.
+func filterBySplit[T HandlerConstraint](variants []*variant[T], r *http.Request) []*variant[T] {
+	var split *TrafficSplit
+	for _, v := range variants {
+		if v.split != nil {
+			split = v.split
+			break
+		}
+	}
+	if split == nil {
+		return variants
+	}
+	name := split.choose(r)
+	if name == "" {
+		return variants
+	}
+	out := make([]*variant[T], 0, len(variants))
+	for _, v := range variants {
+		if v.split == nil || (v.split == split && v.route.Variant == name) {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func indexOfString(list []string, s string) int {
+	for i, x := range list {
+		if x == s {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,69 @@
+package treemux
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTrafficSplit(t *testing.T) {
+	var result string
+	makeHandler := func(name string) HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request, params Params) {
+			result = name + " " + GetRouteData(r).Variant()
+		}
+	}
+
+	split := NewTrafficSplit(SplitByHeader("X-User"))
+	router := New[HandlerFunc]()
+	router.UseContextData = true
+	router.GET("/search", makeHandler("stable"), WithSplit[HandlerFunc](split, "stable", 95))
+	router.GET("/search", makeHandler("canary"), WithSplit[HandlerFunc](split, "canary", 5))
+
+	serve := func(user string) string {
+		result = ""
+		r, _ := newRequest("GET", "/search", nil)
+		r.Header.Set("X-User", user)
+		router.ServeHTTP(httptest.NewRecorder(), r)
+		return result
+	}
+
+	counts := map[string]int{}
+	for i := 0; i < 2000; i++ {
+		user := fmt.Sprintf("user-%d", i)
+		got := serve(user)
+		if again := serve(user); again != got {
+			t.Fatalf("%s: not sticky, got %q then %q", user, got, again)
+		}
+		counts[got]++
+	}
+	if n := counts["canary canary"]; n < 50 || n > 200 {
+		t.Errorf("got %d canary requests of 2000, counts %v", n, counts)
+	}
+	if counts["stable stable"]+counts["canary canary"] != 2000 {
+		t.Errorf("unexpected results %v", counts)
+	}
+
+	split.SetWeight("stable", 0)
+	split.SetWeight("canary", 100)
+	for i := 0; i < 100; i++ {
+		if got := serve(fmt.Sprintf("user-%d", i)); got != "canary canary" {
+			t.Fatalf("got %q after shifting all traffic to canary", got)
+		}
+	}
+	if got := split.Weights(); got["stable"] != 0 || got["canary"] != 100 {
+		t.Errorf("got weights %v", got)
+	}
+
+	r, _ := newRequest("GET", "/search", nil)
+	lr, _ := router.Lookup(httptest.NewRecorder(), r)
+	if lr.Variant != "canary" {
+		t.Errorf("got lookup variant %q, want canary", lr.Variant)
+	}
+
+	split.SetWeight("canary", 0)
+	if got := serve("x"); got != "stable stable" {
+		t.Errorf("got %q with zero weights, want the first variant", got)
+	}
+}
@@ -0,0 +1,379 @@
+package treemux
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// StaticOptions configures static file serving by Group.Static.
+type StaticOptions struct {
+	// IndexFiles is the list of file names to serve for a directory,
+	// the first existing one is served. The default is ["index.html"].
+	IndexFiles []string
+
+	// Browse enables directory listing for directories without an
+	// index file. It is disabled by default.
+	Browse bool
+
+	// Precompressed enables serving precompressed files. If a request
+	// accepts the encoding, "name.br" or "name.gz" is served in place
+	// of "name" with the corresponding `Content-Encoding`.
+	Precompressed bool
+
+	// CacheControl is the list of rules to set the `Cache-Control`
+	// header, the first rule whose pattern matches the file path is used.
+	CacheControl []CacheControlRule
+}
+
+// CacheControlRule sets the `Cache-Control` header for files matching
+// Pattern.
+type CacheControlRule struct {
+	// Pattern is a glob matching the file path relative to the static
+	// root, with a leading slash, e.g. "/assets/**" or "/**.html".
+	// See MatchPattern for the glob syntax.
+	Pattern string
+
+	// Value is the `Cache-Control` header value.
+	Value string
+}
+
+// Static registers GET and HEAD catch-all routes under prefix to serve
+// files from fsys. options may be nil to use the default options.
+//
+// The request path is cleaned by Clean before opening files, thus a
+// request cannot access files outside fsys. A request to a directory
+// without trailing slash, or to a file with trailing slash, is redirected
+// according to Router.RedirectBehavior. Requests which match no file
+// are served by Router.NotFoundHandler.
+//
+// Responses have `ETag` and `Last-Modified` headers, conditional and
+// range requests are handled by [http.ServeContent]. For files without
+// modification time, e.g. files from [embed.FS], the ETag is computed
+// from the file content.
+//
+// Index files are served for directories, e.g. "/static/" serves
+// "index.html". If prefix is the root of the router, the route "/" is not
+// registered, so that the application can register its own root route,
+// thus the root index file is only served at its own path, e.g.
+// "/index.html". SPA registers "/" to serve the index.
+func (g *Group[T]) Static(prefix string, fsys fs.FS, options *StaticOptions) {
+	g.static(prefix, newStaticHandler(g, prefix, fsys, options))
+}
+
+func (g *Group[T]) static(prefix string, h *staticHandler[T]) {
+	if g.mux.Bridge == nil {
+		panic("treemux: Bridge is not configured")
+	}
+	prefix = strings.TrimRight(prefix, "/")
+	handler := g.mux.fromHTTPHandler(h)
+	for _, method := range []string{"GET", "HEAD"} {
+		g.Handle(method, prefix+"/*filepath", handler)
+		// The root catch-all does not match "/", it is registered for
+		// SPA to serve the index, and left to the application for
+		// Static, see the doc of Static.
+		if g.path+prefix != "" || h.spa != nil {
+			g.Handle(method, prefix+"/", handler)
+		}
+	}
+}
+
+// SPAOptions configures single-page-application serving by Group.SPA.
+type SPAOptions struct {
+	StaticOptions
+
+	// Index is the file served for paths which match no file.
+	// The default is "index.html".
+	Index string
+
+	// ExcludePrefixes is the list of path prefixes which never fall back
+	// to Index, e.g. "/api/". The prefixes are matched against the full
+	// request path.
+	ExcludePrefixes []string
+
+	// ExcludeExtensions is the list of file extensions which never fall
+	// back to Index, e.g. ".js" and ".css", thus a missing asset results
+	// in 404 instead of an HTML page. If it is nil, all paths with an
+	// extension are excluded, set it to an empty slice to disable this.
+	ExcludeExtensions []string
+}
+
+// SPA is like Static, but serves a single-page application: requests
+// which match no file are served with the index file and status 200,
+// so that the client side router can handle the path.
+//
+// Requests under ExcludePrefixes or with ExcludeExtensions are not
+// fallen back, they are served by Router.NotFoundHandler. Since SPA
+// registers catch-all routes, routes registered to the router, e.g.
+// API routes, always take priority over the fallback.
+func (g *Group[T]) SPA(prefix string, fsys fs.FS, options *SPAOptions) {
+	var spa SPAOptions
+	if options != nil {
+		spa = *options
+	}
+	if spa.Index == "" {
+		spa.Index = "index.html"
+	}
+	h := newStaticHandler(g, prefix, fsys, &spa.StaticOptions)
+	h.spa = &spa
+	g.static(prefix, h)
+}
+
+type staticHandler[T HandlerConstraint] struct {
+	mux      *Router[T]
+	fsys     fs.FS
+	segments int
+	options  StaticOptions
+	spa      *SPAOptions
+
+	// etags caches the ETags computed from file content.
+	etags sync.Map
+}
+
+func newStaticHandler[T HandlerConstraint](g *Group[T], prefix string, fsys fs.FS, options *StaticOptions) *staticHandler[T] {
+	if strings.Contains(prefix, "/*") {
+		panic("treemux: static prefix must not contain catch-all segment")
+	}
+	h := &staticHandler[T]{
+		mux:      g.mux,
+		fsys:     fsys,
+		segments: strings.Count(strings.TrimRight(g.path+prefix, "/"), "/"),
+	}
+	if options != nil {
+		h.options = *options
+	}
+	if h.options.IndexFiles == nil {
+		h.options.IndexFiles = []string{"index.html"}
+	}
+	return h
+}
+
+func (h *staticHandler[T]) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.serve(w, r) {
+		return
+	}
+	if h.spa != nil && h.canFallback(r) {
+		if info, err := fs.Stat(h.fsys, h.spa.Index); err == nil && !info.IsDir() &&
+			h.serveFile(w, r, h.spa.Index, info) {
+			return
+		}
+	}
+	h.mux.NotFoundHandler(w, r)
+}
+
+// canFallback tells whether the request can be served by the SPA index.
+func (h *staticHandler[T]) canFallback(r *http.Request) bool {
+	urlPath := r.URL.Path
+	for _, prefix := range h.spa.ExcludePrefixes {
+		if strings.HasPrefix(urlPath, prefix) {
+			return false
+		}
+	}
+	ext := path.Ext(urlPath)
+	if ext == "" {
+		return true
+	}
+	if h.spa.ExcludeExtensions == nil {
+		return false
+	}
+	for _, x := range h.spa.ExcludeExtensions {
+		if strings.EqualFold(x, ext) {
+			return false
+		}
+	}
+	return true
+}
+
+// serve serves the file matching the request, it returns false if no
+// file matches.
+func (h *staticHandler[T]) serve(w http.ResponseWriter, r *http.Request) bool {
+	name, ok := h.fileName(r)
+	if !ok {
+		return false
+	}
+	info, err := fs.Stat(h.fsys, name)
+	if err != nil {
+		return false
+	}
+
+	trailingSlash := strings.HasSuffix(r.URL.Path, "/")
+	if info.IsDir() != trailingSlash && r.URL.Path != "/" {
+		if statusCode, ok := h.mux.redirectStatusCode(r.Method); ok {
+			if info.IsDir() {
+				redirect(w, r, r.URL.Path+"/", statusCode)
+			} else {
+				redirect(w, r, strings.TrimRight(r.URL.Path, "/"), statusCode)
+			}
+			return true
+		}
+	}
+
+	if !info.IsDir() {
+		return h.serveFile(w, r, name, info)
+	}
+	for _, index := range h.options.IndexFiles {
+		indexName := path.Join(name, index)
+		if indexInfo, err := fs.Stat(h.fsys, indexName); err == nil && !indexInfo.IsDir() {
+			return h.serveFile(w, r, indexName, indexInfo)
+		}
+	}
+	if h.options.Browse {
+		return h.serveDir(w, name)
+	}
+	return false
+}
+
+// fileName returns the name of the file in fsys to serve the request.
+func (h *staticHandler[T]) fileName(r *http.Request) (string, bool) {
+	rest, _ := stripPathSegments(r.URL.Path, h.segments)
+	name := strings.Trim(Clean(rest), "/")
+	if name == "" {
+		name = "."
+	}
+	return name, fs.ValidPath(name)
+}
+
+var precompressedEncodings = []struct {
+	encoding, ext string
+}{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+func (h *staticHandler[T]) serveFile(w http.ResponseWriter, r *http.Request, name string, info fs.FileInfo) bool {
+	header := w.Header()
+	contentName := name
+	if h.options.Precompressed {
+		header.Add("Vary", "Accept-Encoding")
+		for _, pc := range precompressedEncodings {
+			if !acceptsEncoding(r.Header.Get("Accept-Encoding"), pc.encoding) {
+				continue
+			}
+			if ci, err := fs.Stat(h.fsys, name+pc.ext); err == nil && !ci.IsDir() {
+				header.Set("Content-Encoding", pc.encoding)
+				contentName, info = name+pc.ext, ci
+				break
+			}
+		}
+	}
+
+	f, err := h.fsys.Open(contentName)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	content, ok := f.(io.ReadSeeker)
+	if !ok {
+		data, err := io.ReadAll(f)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return true
+		}
+		content = bytes.NewReader(data)
+	}
+
+	if header.Get("Content-Type") == "" {
+		ctype := mime.TypeByExtension(path.Ext(name))
+		if ctype == "" && contentName != name {
+			// Don't let http.ServeContent sniff the compressed content.
+			ctype = "application/octet-stream"
+		}
+		if ctype != "" {
+			header.Set("Content-Type", ctype)
+		}
+	}
+	if cc := h.cacheControl(name); cc != "" {
+		header.Set("Cache-Control", cc)
+	}
+	if etag := h.etag(contentName, info, content); etag != "" {
+		header.Set("ETag", etag)
+	}
+	http.ServeContent(w, r, name, info.ModTime(), content)
+	return true
+}
+
+func (h *staticHandler[T]) cacheControl(name string) string {
+	for _, rule := range h.options.CacheControl {
+		if matchGlob(rule.Pattern, "/"+name) {
+			return rule.Value
+		}
+	}
+	return ""
+}
+
+// etag returns the ETag of a file. If the file has no modification
+// time, the ETag is computed from the content.
+func (h *staticHandler[T]) etag(name string, info fs.FileInfo, content io.ReadSeeker) string {
+	if !info.ModTime().IsZero() {
+		return fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size())
+	}
+	if etag, ok := h.etags.Load(name); ok {
+		return etag.(string)
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(hash, content); err != nil {
+		return ""
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return ""
+	}
+	etag := `"` + hex.EncodeToString(hash.Sum(nil)[:16]) + `"`
+	h.etags.Store(name, etag)
+	return etag
+}
+
+func (h *staticHandler[T]) serveDir(w http.ResponseWriter, name string) bool {
+	entries, err := fs.ReadDir(h.fsys, name)
+	if err != nil {
+		return false
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprintf(w, "<!doctype html>\n<meta name=\"viewport\" content=\"width=device-width\">\n<pre>\n")
+	for _, entry := range entries {
+		entryName := entry.Name()
+		if entry.IsDir() {
+			entryName += "/"
+		}
+		link := url.URL{Path: entryName}
+		fmt.Fprintf(w, "<a href=\"%s\">%s</a>\n", link.String(), html.EscapeString(entryName))
+	}
+	fmt.Fprintf(w, "</pre>\n")
+	return true
+}
+
+// acceptsEncoding tells whether the `Accept-Encoding` header value
+// accepts encoding, an encoding with "q=0" is not accepted.
+func acceptsEncoding(acceptEncoding, encoding string) bool {
+	accepted, wildcard := -1, -1
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		coding = strings.TrimSpace(coding)
+		ok := 1
+		key, value, _ := strings.Cut(strings.TrimSpace(params), "=")
+		if strings.EqualFold(strings.TrimSpace(key), "q") {
+			if q, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil && q == 0 {
+				ok = 0
+			}
+		}
+		if strings.EqualFold(coding, encoding) {
+			accepted = ok
+		} else if coding == "*" {
+			wildcard = ok
+		}
+	}
+	if accepted < 0 {
+		accepted = wildcard
+	}
+	return accepted > 0
+}
@@ -0,0 +1,207 @@
+package treemux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func newTestFS() fstest.MapFS {
+	modTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	return fstest.MapFS{
+		"index.html":      {Data: []byte("<h1>home</h1>"), ModTime: modTime},
+		"app.js":          {Data: []byte("console.log(1)"), ModTime: modTime},
+		"app.js.gz":       {Data: []byte("gzipped"), ModTime: modTime},
+		"app.js.br":       {Data: []byte("brotli"), ModTime: modTime},
+		"docs/guide.txt":  {Data: []byte("0123456789")},
+		"assets/logo.svg": {Data: []byte("<svg/>"), ModTime: modTime},
+		"empty/.keep":     {Data: nil, ModTime: modTime},
+	}
+}
+
+func TestStatic(t *testing.T) {
+	router := New[HandlerFunc]()
+	router.Static("/static", newTestFS(), &StaticOptions{
+		Browse:        true,
+		Precompressed: true,
+		CacheControl: []CacheControlRule{
+			{Pattern: "/assets/**", Value: "public, max-age=31536000"},
+			{Pattern: "/*.html", Value: "no-cache"},
+		},
+	})
+
+	tests := []struct {
+		method, path string
+		header       map[string]string
+		wantCode     int
+		wantBody     string
+		wantHeader   map[string]string
+	}{
+		{"GET", "/static/", nil, 200, "<h1>home</h1>", map[string]string{"Cache-Control": "no-cache"}},
+		{"GET", "/static", nil, 301, "", map[string]string{"Location": "/static/"}},
+		{"HEAD", "/static/app.js", nil, 200, "", map[string]string{"Content-Length": "14"}},
+		{"GET", "/static/app.js", nil, 200, "console.log(1)", map[string]string{
+			"Content-Type":  "text/javascript; charset=utf-8",
+			"Vary":          "Accept-Encoding",
+			"Last-Modified": "Tue, 02 Jan 2024 03:04:05 GMT",
+		}},
+		{"GET", "/static/app.js", map[string]string{"Accept-Encoding": "gzip, br"}, 200, "brotli", map[string]string{
+			"Content-Encoding": "br",
+			"Content-Type":     "text/javascript; charset=utf-8",
+		}},
+		{"GET", "/static/app.js", map[string]string{"Accept-Encoding": "gzip, br;q=0"}, 200, "gzipped", map[string]string{
+			"Content-Encoding": "gzip",
+		}},
+		{"GET", "/static/assets/logo.svg", nil, 200, "<svg/>", map[string]string{"Cache-Control": "public, max-age=31536000"}},
+		{"GET", "/static/docs/guide.txt", map[string]string{"Range": "bytes=2-4"}, 206, "234", nil},
+		{"GET", "/static/app.js/", nil, 301, "", map[string]string{"Location": "/static/app.js"}},
+		{"GET", "/static/../static/app.js", nil, 404, "", nil},
+		{"GET", "/static/docs/../../index.html", nil, 200, "<h1>home</h1>", nil},
+		{"GET", "/static/missing.js", nil, 404, "", nil},
+		{"POST", "/static/app.js", nil, 405, "", nil},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r, _ := newRequest(tt.method, tt.path, nil)
+		for k, v := range tt.header {
+			r.Header.Set(k, v)
+		}
+		router.ServeHTTP(w, r)
+		if w.Code != tt.wantCode {
+			t.Errorf("%s %s: got code %d, want %d", tt.method, tt.path, w.Code, tt.wantCode)
+			continue
+		}
+		if tt.wantBody != "" && w.Body.String() != tt.wantBody {
+			t.Errorf("%s %s: got body %q, want %q", tt.method, tt.path, w.Body.String(), tt.wantBody)
+		}
+		for k, v := range tt.wantHeader {
+			if got := w.Header().Get(k); got != v {
+				t.Errorf("%s %s: got header %s=%q, want %q", tt.method, tt.path, k, got, v)
+			}
+		}
+	}
+}
+
+func TestStaticConditional(t *testing.T) {
+	router := New[HandlerFunc]()
+	router.Static("/", newTestFS(), nil)
+
+	for _, path := range []string{"/app.js", "/docs/guide.txt"} {
+		w := httptest.NewRecorder()
+		r, _ := newRequest("GET", path, nil)
+		router.ServeHTTP(w, r)
+		etag := w.Header().Get("ETag")
+		if w.Code != 200 || etag == "" {
+			t.Fatalf("%s: got code %d etag %q", path, w.Code, etag)
+		}
+
+		w = httptest.NewRecorder()
+		r, _ = newRequest("GET", path, nil)
+		r.Header.Set("If-None-Match", etag)
+		router.ServeHTTP(w, r)
+		if w.Code != http.StatusNotModified {
+			t.Errorf("%s: got code %d, want 304", path, w.Code)
+		}
+	}
+
+	// Directory listing is disabled by default.
+	w := httptest.NewRecorder()
+	r, _ := newRequest("GET", "/empty/", nil)
+	router.ServeHTTP(w, r)
+	if w.Code != 404 {
+		t.Errorf("got code %d, want 404", w.Code)
+	}
+}
+
+func TestStaticBrowse(t *testing.T) {
+	router := New[HandlerFunc]()
+	router.NewGroup("/files").Static("/", newTestFS(), &StaticOptions{IndexFiles: []string{}, Browse: true})
+
+	w := httptest.NewRecorder()
+	r, _ := newRequest("GET", "/files/", nil)
+	router.ServeHTTP(w, r)
+	body := w.Body.String()
+	for _, want := range []string{`<a href="app.js">app.js</a>`, `<a href="docs/">docs/</a>`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("listing %q does not contain %q", body, want)
+		}
+	}
+}
+
+func TestSPA(t *testing.T) {
+	router := New[HandlerFunc]()
+	router.GET("/api/users/:id", func(w http.ResponseWriter, r *http.Request, params Params) {
+		w.Write([]byte("user " + params.Get("id")))
+	})
+	router.NotFoundHandler = func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}
+	router.SPA("/", newTestFS(), &SPAOptions{
+		ExcludePrefixes: []string{"/api/"},
+		StaticOptions: StaticOptions{
+			CacheControl: []CacheControlRule{{Pattern: "/index.html", Value: "no-cache"}},
+		},
+	})
+
+	tests := []struct {
+		path     string
+		wantCode int
+		wantBody string
+	}{
+		{"/", 200, "<h1>home</h1>"},
+		{"/app.js", 200, "console.log(1)"},
+		{"/users/42/profile", 200, "<h1>home</h1>"},
+		{"/api/users/42", 200, "user 42"},
+		{"/api/orders", http.StatusTeapot, ""},
+		{"/missing.js", http.StatusTeapot, ""},
+		{"/data.json", http.StatusTeapot, ""},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r, _ := newRequest("GET", tt.path, nil)
+		router.ServeHTTP(w, r)
+		if w.Code != tt.wantCode || (tt.wantBody != "" && w.Body.String() != tt.wantBody) {
+			t.Errorf("%s: got %d %q, want %d %q", tt.path, w.Code, w.Body.String(), tt.wantCode, tt.wantBody)
+		}
+		if tt.wantCode == 200 && strings.Contains(tt.wantBody, "home") && w.Header().Get("Cache-Control") != "no-cache" {
+			t.Errorf("%s: got Cache-Control %q", tt.path, w.Header().Get("Cache-Control"))
+		}
+	}
+
+	router = New[HandlerFunc]()
+	router.SPA("/", newTestFS(), &SPAOptions{ExcludeExtensions: []string{".js"}})
+	w := httptest.NewRecorder()
+	r, _ := newRequest("GET", "/data.json", nil)
+	router.ServeHTTP(w, r)
+	if w.Code != 200 {
+		t.Errorf("got code %d, want 200", w.Code)
+	}
+}
+
+func TestStaticRootRoute(t *testing.T) {
+	router := New[HandlerFunc]()
+	router.Static("/", newTestFS(), nil)
+
+	for path, wantCode := range map[string]int{"/": 404, "/index.html": 200} {
+		w := httptest.NewRecorder()
+		r, _ := newRequest("GET", path, nil)
+		router.ServeHTTP(w, r)
+		if w.Code != wantCode {
+			t.Errorf("%s: expected %d, got %d", path, wantCode, w.Code)
+		}
+	}
+
+	router.GET("/", func(w http.ResponseWriter, r *http.Request, params Params) {
+		w.Write([]byte("home"))
+	})
+
+	w := httptest.NewRecorder()
+	r, _ := newRequest("GET", "/", nil)
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || w.Body.String() != "home" {
+		t.Errorf("expected the root route, got %d %q", w.Code, w.Body.String())
+	}
+}
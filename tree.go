@@ -37,6 +37,25 @@ type node[T HandlerConstraint] struct {
 	// If this node is the end of the URL, then call the handler, if applicable.
 	leafHandlers map[string]T
 
+	// The routes registered to this node, keyed by method.
+	leafRoutes map[string]*Route[T]
+
+	// The variants registered to this node, keyed by method.
+	leafVariants map[string]*variantSet[T]
+
+	// If true, some routes of this node have CORS policy.
+	hasCORS bool
+
+	// The child router mounted at this node, see Group.MountRouter.
+	subRouter *subRouter[T]
+
+	// The aliases registered to this node, keyed by method, see WithAlias.
+	leafAliases map[string]*routeAlias
+
+	// The locales of the localized routes registered to this node,
+	// keyed by method, see Group.HandleLocalized.
+	leafLocales map[string][]string
+
 	// The names of the parameters to apply.
 	leafParamNames []string
 }
@@ -68,6 +87,67 @@ func (n *node[T]) setHandler(verb string, handler T, implicitHead bool) {
 	}
 }
 
+// getHandler returns the handler registered for method, if there is no
+// handler for method, it falls back to the handler registered by Any.
+func (n *node[T]) getHandler(method string) T {
+	if handler, ok := n.leafHandlers[method]; ok {
+		return handler
+	}
+	return n.leafHandlers[MethodAny]
+}
+
+// getRoute returns the route registered for method, it falls back to
+// the route registered by Any. An implicit HEAD route is reported as
+// the GET route which serves the request.
+func (n *node[T]) getRoute(method string) *Route[T] {
+	if method == "HEAD" && n.implicitHead {
+		method = "GET"
+	}
+	if route, ok := n.leafRoutes[method]; ok {
+		return route
+	}
+	return n.leafRoutes[MethodAny]
+}
+
+// allowedMethods returns the sorted methods registered to the node,
+// excluding the MethodAny key.
+func (n *node[T]) allowedMethods() []string {
+	methods := getSortedKeys(n.leafHandlers)
+	for i, m := range methods {
+		if m == MethodAny {
+			return append(methods[:i], methods[i+1:]...)
+		}
+	}
+	return methods
+}
+
+// leafMethod returns the key of leafHandlers which serves method.
+func (n *node[T]) leafMethod(method string) string {
+	if method == "HEAD" && n.implicitHead {
+		return "GET"
+	}
+	if _, ok := n.leafHandlers[method]; ok {
+		return method
+	}
+	return MethodAny
+}
+
+// routePath returns the pattern reported as RoutePath for method,
+// it is the pattern of the canonical route for aliases.
+func (n *node[T]) routePath(method string) string {
+	if alias := n.leafAliases[n.leafMethod(method)]; alias != nil {
+		return alias.path
+	}
+	return n.fullPath
+}
+
+func (n *node[T]) setRoute(verb string, route *Route[T]) {
+	if n.leafRoutes == nil {
+		n.leafRoutes = make(map[string]*Route[T])
+	}
+	n.leafRoutes[verb] = route
+}
+
 func (n *node[T]) addPath(path string, paramNames []string, inStaticToken bool) *node[T] {
 	leaf := len(path) == 0
 	if leaf {
@@ -264,7 +344,7 @@ func (n *node[T]) search(method, path string, isValid func(T) bool) (found *node
 		if len(n.leafHandlers) == 0 {
 			return
 		}
-		return n, n.leafHandlers[method], nil
+		return n, n.getHandler(method), nil
 	}
 
 	// First see if this matches a static token.
@@ -335,7 +415,7 @@ func (n *node[T]) search(method, path string, isValid func(T) bool) (found *node
 	if catchAllChild != nil {
 		// Hit the catchall, so just assign the whole remaining path if it
 		// has a matching handler.
-		handler = catchAllChild.leafHandlers[method]
+		handler = catchAllChild.getHandler(method)
 		// Found a handler, or we found a catchall node without a handler.
 		// Either way, return it since there's nothing left to check after this.
 		if isValid(handler) || found == nil {
@@ -361,7 +441,7 @@ func (n *node[T]) searchRegexChild(method, path string, isValid func(T) bool) (f
 		}
 
 		found = child
-		handler = child.leafHandlers[method]
+		handler = child.getHandler(method)
 		params = getRegexMatchParams(re, match)
 		for i, param := range params {
 			unescaped, err := unescape(param)
@@ -382,11 +462,37 @@ func (n *node[T]) searchRegexChild(method, path string, isValid func(T) bool) (f
 	return
 }
 
-func (n *node[_]) dumpTree(prefix, nodeType string) string {
+// walk calls fn for the node and all its descendants.
+func (n *node[T]) walk(fn func(n *node[T])) {
+	fn(n)
+	for _, child := range n.staticChild {
+		child.walk(fn)
+	}
+	if n.wildcardChild != nil {
+		n.wildcardChild.walk(fn)
+	}
+	for _, child := range n.regexChild {
+		child.walk(fn)
+	}
+	if n.catchAllChild != nil {
+		n.catchAllChild.walk(fn)
+	}
+}
+
+func (n *node[T]) dumpTree(prefix, nodeType string) string {
 	methods := getSortedKeys(n.leafHandlers)
 	line := fmt.Sprintf("%s %02d %s%s [%d] %v params %v\n",
 		prefix, n.priority, nodeType, n.path, len(n.staticChild), methods, n.leafParamNames)
 	prefix += "  "
+	for _, method := range getSortedKeys(n.leafRoutes) {
+		route := n.leafRoutes[method]
+		if len(route.Middlewares) > 0 {
+			line += fmt.Sprintf("%s - %s middlewares %v\n", prefix, method, route.MiddlewareNames())
+		}
+		if route.Timeout > 0 || route.MaxBodySize > 0 {
+			line += fmt.Sprintf("%s - %s timeout %v max body %d\n", prefix, method, route.Timeout, route.MaxBodySize)
+		}
+	}
 	for _, node := range n.staticChild {
 		line += node.dumpTree(prefix, "")
 	}
@@ -0,0 +1,366 @@
+package treemux
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// BalancingPolicy decides how an UpstreamPool picks an upstream.
+type BalancingPolicy int
+
+const (
+	// RoundRobin picks the healthy upstreams in turn.
+	RoundRobin BalancingPolicy = iota
+
+	// LeastConnections picks the healthy upstream with the fewest
+	// requests in flight.
+	LeastConnections
+
+	// ConsistentHash picks the upstream by consistent hashing on the
+	// path parameter PoolOptions.HashParam, requests with the same
+	// parameter value go to the same upstream while it is healthy.
+	ConsistentHash
+)
+
+// ErrNoHealthyUpstream is reported when all upstreams of a pool are
+// unhealthy or ejected, proxies respond 503 Service Unavailable for it.
+var ErrNoHealthyUpstream = errors.New("treemux: no healthy upstream")
+
+// PoolOptions configures an UpstreamPool.
+type PoolOptions struct {
+	// Balancing is the load balancing policy, the default is RoundRobin.
+	Balancing BalancingPolicy
+
+	// HashParam is the path parameter to hash when Balancing is
+	// ConsistentHash. Requests without the parameter are balanced
+	// by RoundRobin.
+	HashParam string
+
+	// MaxFails is the number of consecutive failures after which an
+	// upstream is ejected passively. A failure is an error reaching the
+	// upstream, or a response with status 502, 503 or 504.
+	// Zero means 3, a negative value disables passive ejection.
+	MaxFails int
+
+	// EjectDuration is how long an ejected upstream is not picked.
+	// Zero means 30 seconds.
+	EjectDuration time.Duration
+
+	// HealthCheck, if not nil, enables active health checks.
+	HealthCheck *HealthCheck
+}
+
+// HealthCheck configures active HTTP health checks of an UpstreamPool.
+type HealthCheck struct {
+	// Path is the path to request on each upstream, e.g. "/healthz".
+	Path string
+
+	// Interval is the interval between checks, zero means 10 seconds.
+	Interval time.Duration
+
+	// Timeout is the timeout of a check request, zero means 2 seconds.
+	Timeout time.Duration
+
+	// Client is used to perform the checks, if nil, a client with
+	// Timeout is used.
+	Client *http.Client
+}
+
+// UpstreamStats is the statistics of an upstream.
+type UpstreamStats struct {
+	URL string
+
+	// Healthy is the result of the last active health check, it is
+	// always true if health checks are not enabled.
+	Healthy bool
+
+	// Ejected tells that the upstream is ejected by passive ejection.
+	Ejected bool
+
+	ActiveRequests      int64
+	TotalRequests       uint64
+	TotalFailures       uint64
+	ConsecutiveFailures int64
+}
+
+// UpstreamPool is a group of upstream instances which serve the same
+// service. It is created by Router.AddUpstreamPool, and used by proxies
+// by ProxyOptions.Pool.
+type UpstreamPool struct {
+	counter uint64 // accessed atomically, keep it 64-bit aligned
+
+	name      string
+	options   PoolOptions
+	upstreams []*upstream
+	ring      []ringEntry
+
+	stopOnce  sync.Once
+	stopCheck chan struct{}
+}
+
+type upstream struct {
+	// Accessed atomically, keep them 64-bit aligned.
+	ejectedUntil int64 // unix nano
+	active       int64
+	requests     uint64
+	failures     uint64
+	consecFail   int64
+	unhealthy    int32
+
+	url *url.URL
+}
+
+type ringEntry struct {
+	hash     uint32
+	upstream *upstream
+}
+
+// AddUpstreamPool creates an UpstreamPool of the given upstream URLs and
+// registers it to the router by name, thus it can be referenced by
+// proxies of many routes. Only the scheme and host of the URLs are used.
+// options may be nil.
+//
+// If health checks are configured, they run in background until the
+// pool is closed by UpstreamPool.Close.
+func (t *Router[T]) AddUpstreamPool(name string, urls []string, options *PoolOptions) (*UpstreamPool, error) {
+	if len(urls) == 0 {
+		return nil, fmt.Errorf("treemux: upstream pool %q has no upstream", name)
+	}
+	pool := &UpstreamPool{name: name}
+	if options != nil {
+		pool.options = *options
+	}
+	if pool.options.Balancing == ConsistentHash && pool.options.HashParam == "" {
+		return nil, fmt.Errorf("treemux: upstream pool %q uses consistent hashing without HashParam", name)
+	}
+	for _, s := range urls {
+		u, err := url.Parse(s)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("treemux: invalid upstream %q of pool %q", s, name)
+		}
+		pool.upstreams = append(pool.upstreams, &upstream{url: &url.URL{Scheme: u.Scheme, Host: u.Host}})
+	}
+	if pool.options.Balancing == ConsistentHash {
+		pool.buildRing()
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if _, ok := t.pools[name]; ok {
+		return nil, fmt.Errorf("treemux: upstream pool %q already exists", name)
+	}
+	if t.pools == nil {
+		t.pools = make(map[string]*UpstreamPool)
+	}
+	t.pools[name] = pool
+	if pool.options.HealthCheck != nil {
+		pool.startHealthCheck()
+	}
+	return pool, nil
+}
+
+// UpstreamPool returns the pool registered by name, or nil if no pool
+// is registered with the name.
+func (t *Router[T]) UpstreamPool(name string) *UpstreamPool {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	return t.pools[name]
+}
+
+// UpstreamPools returns all pools registered to the router, sorted by name.
+func (t *Router[T]) UpstreamPools() []*UpstreamPool {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	pools := make([]*UpstreamPool, 0, len(t.pools))
+	for _, pool := range t.pools {
+		pools = append(pools, pool)
+	}
+	sort.Slice(pools, func(i, j int) bool { return pools[i].name < pools[j].name })
+	return pools
+}
+
+// Name returns the name of the pool.
+func (p *UpstreamPool) Name() string {
+	return p.name
+}
+
+// Stats returns the statistics of the upstreams.
+func (p *UpstreamPool) Stats() []UpstreamStats {
+	now := time.Now().UnixNano()
+	stats := make([]UpstreamStats, 0, len(p.upstreams))
+	for _, u := range p.upstreams {
+		stats = append(stats, UpstreamStats{
+			URL:                 u.url.String(),
+			Healthy:             atomic.LoadInt32(&u.unhealthy) == 0,
+			Ejected:             atomic.LoadInt64(&u.ejectedUntil) > now,
+			ActiveRequests:      atomic.LoadInt64(&u.active),
+			TotalRequests:       atomic.LoadUint64(&u.requests),
+			TotalFailures:       atomic.LoadUint64(&u.failures),
+			ConsecutiveFailures: atomic.LoadInt64(&u.consecFail),
+		})
+	}
+	return stats
+}
+
+// Close stops the health checks of the pool.
+func (p *UpstreamPool) Close() {
+	p.stopOnce.Do(func() {
+		if p.stopCheck != nil {
+			close(p.stopCheck)
+		}
+	})
+}
+
+func (u *upstream) available(now int64) bool {
+	return atomic.LoadInt32(&u.unhealthy) == 0 && atomic.LoadInt64(&u.ejectedUntil) <= now
+}
+
+// pick picks an upstream for a request with path parameters params.
+func (p *UpstreamPool) pick(params Params) (*upstream, error) {
+	now := time.Now().UnixNano()
+	switch p.options.Balancing {
+	case LeastConnections:
+		var best *upstream
+		var bestActive int64
+		start := int(atomic.AddUint64(&p.counter, 1) % uint64(len(p.upstreams)))
+		for i := range p.upstreams {
+			u := p.upstreams[(start+i)%len(p.upstreams)]
+			if !u.available(now) {
+				continue
+			}
+			if active := atomic.LoadInt64(&u.active); best == nil || active < bestActive {
+				best, bestActive = u, active
+			}
+		}
+		if best != nil {
+			return best, nil
+		}
+	case ConsistentHash:
+		if key := params.Get(p.options.HashParam); key != "" {
+			h := crc32.ChecksumIEEE([]byte(key))
+			i := sort.Search(len(p.ring), func(i int) bool { return p.ring[i].hash >= h })
+			for j := 0; j < len(p.ring); j++ {
+				u := p.ring[(i+j)%len(p.ring)].upstream
+				if u.available(now) {
+					return u, nil
+				}
+			}
+			return nil, ErrNoHealthyUpstream
+		}
+		fallthrough
+	default:
+		n := atomic.AddUint64(&p.counter, 1)
+		for i := 0; i < len(p.upstreams); i++ {
+			u := p.upstreams[(n+uint64(i))%uint64(len(p.upstreams))]
+			if u.available(now) {
+				return u, nil
+			}
+		}
+	}
+	return nil, ErrNoHealthyUpstream
+}
+
+// ringReplicas is the number of points of each upstream on the hash ring.
+const ringReplicas = 100
+
+func (p *UpstreamPool) buildRing() {
+	p.ring = make([]ringEntry, 0, len(p.upstreams)*ringReplicas)
+	for _, u := range p.upstreams {
+		for i := 0; i < ringReplicas; i++ {
+			h := crc32.ChecksumIEEE([]byte(u.url.Host + "#" + strconv.Itoa(i)))
+			p.ring = append(p.ring, ringEntry{hash: h, upstream: u})
+		}
+	}
+	sort.Slice(p.ring, func(i, j int) bool { return p.ring[i].hash < p.ring[j].hash })
+}
+
+func (p *UpstreamPool) begin(u *upstream) {
+	atomic.AddInt64(&u.active, 1)
+	atomic.AddUint64(&u.requests, 1)
+}
+
+// cancel records a request to u which is canceled by the client.
+func (p *UpstreamPool) cancel(u *upstream) {
+	atomic.AddInt64(&u.active, -1)
+}
+
+// done records the result of a request to u.
+func (p *UpstreamPool) done(u *upstream, failed bool) {
+	atomic.AddInt64(&u.active, -1)
+	if !failed {
+		atomic.StoreInt64(&u.consecFail, 0)
+		return
+	}
+	atomic.AddUint64(&u.failures, 1)
+	fails := atomic.AddInt64(&u.consecFail, 1)
+	maxFails := p.options.MaxFails
+	if maxFails == 0 {
+		maxFails = 3
+	}
+	if maxFails > 0 && fails >= int64(maxFails) {
+		duration := p.options.EjectDuration
+		if duration <= 0 {
+			duration = 30 * time.Second
+		}
+		atomic.StoreInt64(&u.ejectedUntil, time.Now().Add(duration).UnixNano())
+		atomic.StoreInt64(&u.consecFail, 0)
+	}
+}
+
+func (p *UpstreamPool) startHealthCheck() {
+	hc := p.options.HealthCheck
+	interval := hc.Interval
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	client := hc.Client
+	if client == nil {
+		timeout := hc.Timeout
+		if timeout <= 0 {
+			timeout = 2 * time.Second
+		}
+		client = &http.Client{Timeout: timeout}
+	}
+
+	p.stopCheck = make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			p.checkHealth(client, hc.Path)
+			select {
+			case <-p.stopCheck:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (p *UpstreamPool) checkHealth(client *http.Client, path string) {
+	var wg sync.WaitGroup
+	for _, u := range p.upstreams {
+		wg.Add(1)
+		go func(u *upstream) {
+			defer wg.Done()
+			var unhealthy int32 = 1
+			resp, err := client.Get(u.url.String() + path)
+			if err == nil {
+				resp.Body.Close()
+				if resp.StatusCode < 400 {
+					unhealthy = 0
+				}
+			}
+			atomic.StoreInt32(&u.unhealthy, unhealthy)
+		}(u)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,186 @@
+package treemux
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestUpstreams(t *testing.T, n int) (urls []string, healthy []*int32, failing []*int32) {
+	for i := 0; i < n; i++ {
+		i := i
+		var isHealthy int32 = 1
+		var isFailing int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/healthz" {
+				if atomic.LoadInt32(&isHealthy) == 0 {
+					w.WriteHeader(http.StatusInternalServerError)
+				}
+				return
+			}
+			if atomic.LoadInt32(&isFailing) != 0 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			fmt.Fprintf(w, "%d", i)
+		}))
+		t.Cleanup(srv.Close)
+		urls = append(urls, srv.URL)
+		healthy = append(healthy, &isHealthy)
+		failing = append(failing, &isFailing)
+	}
+	return
+}
+
+func TestUpstreamPool(t *testing.T) {
+	urls, _, failing := newTestUpstreams(t, 3)
+
+	router := New[HandlerFunc]()
+	rrPool, err := router.AddUpstreamPool("users", urls, &PoolOptions{MaxFails: 2, EjectDuration: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hashPool, err := router.AddUpstreamPool("carts", urls, &PoolOptions{Balancing: ConsistentHash, HashParam: "id"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := router.AddUpstreamPool("users", urls, nil); err == nil {
+		t.Error("expected error for duplicate pool")
+	}
+
+	rrProxy, _ := NewProxy("/internal/users/:id", &ProxyOptions{Pool: router.UpstreamPool("users")})
+	hashProxy, _ := NewProxy("/carts/:id", &ProxyOptions{Pool: hashPool})
+	router.Proxy("GET", "/users/:id", rrProxy)
+	router.Proxy("GET", "/v2/users/:id", rrProxy)
+	router.Proxy("GET", "/carts/:id", hashProxy)
+
+	get := func(path string) (int, string) {
+		w := httptest.NewRecorder()
+		r, _ := newRequest("GET", path, nil)
+		router.ServeHTTP(w, r)
+		body, _ := io.ReadAll(w.Body)
+		return w.Code, string(body)
+	}
+
+	seen := map[string]int{}
+	for i := 0; i < 6; i++ {
+		_, body := get("/users/1")
+		seen[body]++
+	}
+	if len(seen) != 3 || seen["0"] != 2 {
+		t.Errorf("round robin got %v", seen)
+	}
+
+	for _, id := range []string{"a", "b", "c", "d"} {
+		_, first := get("/carts/" + id)
+		for i := 0; i < 3; i++ {
+			if _, body := get("/carts/" + id); body != first {
+				t.Errorf("consistent hash of %s got %s and %s", id, first, body)
+			}
+		}
+	}
+
+	// Passive ejection.
+	atomic.StoreInt32(failing[1], 1)
+	for i := 0; i < 6; i++ {
+		get("/v2/users/1")
+	}
+	stats := rrPool.Stats()
+	if !stats[1].Ejected || stats[1].TotalFailures != 2 || stats[0].Ejected {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	for i := 0; i < 6; i++ {
+		if code, body := get("/users/1"); code != 200 || body == "1" {
+			t.Errorf("got %d %q from an ejected upstream", code, body)
+		}
+	}
+
+	var pools []string
+	for _, route := range router.Routes() {
+		if route.Upstream != nil {
+			pools = append(pools, route.Path+"="+route.Upstream.Name())
+		}
+	}
+	if want := "[/carts/:id=carts /users/:id=users /v2/users/:id=users]"; fmt.Sprint(pools) != want {
+		t.Errorf("got routes %v, want %s", pools, want)
+	}
+	if got := router.UpstreamPools(); len(got) != 2 || got[0].Name() != "carts" {
+		t.Errorf("unexpected pools %v", got)
+	}
+}
+
+func TestUpstreamPoolHealthCheck(t *testing.T) {
+	urls, healthy, _ := newTestUpstreams(t, 2)
+	atomic.StoreInt32(healthy[0], 0)
+
+	router := New[HandlerFunc]()
+	pool, err := router.AddUpstreamPool("svc", urls, &PoolOptions{
+		Balancing:   LeastConnections,
+		HealthCheck: &HealthCheck{Path: "/healthz", Interval: 10 * time.Millisecond},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	proxy, _ := NewProxy("/", &ProxyOptions{Pool: pool})
+	router.Proxy("GET", "/", proxy)
+
+	deadline := time.Now().Add(time.Second)
+	for pool.Stats()[0].Healthy && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	for i := 0; i < 4; i++ {
+		w := httptest.NewRecorder()
+		r, _ := newRequest("GET", "/", nil)
+		router.ServeHTTP(w, r)
+		if w.Body.String() != "1" {
+			t.Errorf("got %q, want the healthy upstream", w.Body.String())
+		}
+	}
+
+	atomic.StoreInt32(healthy[1], 0)
+	deadline = time.Now().Add(time.Second)
+	for pool.Stats()[1].Healthy && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	w := httptest.NewRecorder()
+	r, _ := newRequest("GET", "/", nil)
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("got code %d, want 503", w.Code)
+	}
+}
+
+func TestUpstreamPoolClientCancel(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer upstream.Close()
+
+	router := New[HandlerFunc]()
+	pool, err := router.AddUpstreamPool("slow", []string{upstream.URL}, &PoolOptions{MaxFails: 1, EjectDuration: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxy, _ := NewProxy("/", &ProxyOptions{Pool: pool})
+	router.Proxy("GET", "/", proxy)
+
+	for i := 0; i < 3; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(10*time.Millisecond, cancel)
+		r, _ := newRequest("GET", "/", nil)
+		router.ServeHTTP(httptest.NewRecorder(), r.WithContext(ctx))
+	}
+	stats := pool.Stats()
+	if stats[0].Ejected || stats[0].TotalFailures != 0 || stats[0].ActiveRequests != 0 {
+		t.Errorf("expected client cancellations not to count as failures, got %+v", stats[0])
+	}
+}
@@ -3,7 +3,9 @@ package treemux
 import (
 	"net/http"
 	"net/url"
+	"reflect"
 	"regexp"
+	"runtime"
 	"sort"
 )
 
@@ -47,6 +49,21 @@ func redirect(w http.ResponseWriter, r *http.Request, newPath string, statusCode
 	http.Redirect(w, r, newURL.String(), statusCode)
 }
 
+// redirectEscaped is like redirect, but newPath is already escaped.
+func redirectEscaped(w http.ResponseWriter, r *http.Request, newPath string, statusCode int) {
+	unescaped, err := unescape(newPath)
+	if err != nil {
+		unescaped = newPath
+	}
+	newURL := url.URL{
+		Path:     unescaped,
+		RawPath:  newPath,
+		RawQuery: r.URL.RawQuery,
+		Fragment: r.URL.Fragment,
+	}
+	http.Redirect(w, r, newURL.String(), statusCode)
+}
+
 func reverseSlice[S []E, E any](s S) {
 	for i := 0; i < len(s)/2; i++ {
 		j := len(s) - i - 1
@@ -57,3 +74,14 @@ func reverseSlice[S []E, E any](s S) {
 func unescape(path string) (string, error) {
 	return url.PathUnescape(path)
 }
+
+func getFuncName(f any) string {
+	v := reflect.ValueOf(f)
+	if v.Kind() != reflect.Func || v.IsNil() {
+		return ""
+	}
+	if fn := runtime.FuncForPC(v.Pointer()); fn != nil {
+		return fn.Name()
+	}
+	return ""
+}
@@ -0,0 +1,200 @@
+package treemux
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// variant is one of several handlers registered for the same method
+// and pattern, which are distinguished by request properties other
+// than the path.
+type variant[T HandlerConstraint] struct {
+	route   *Route[T]
+	handler T
+
+	produces []mediaRange
+	consumes []mediaRange
+	version  apiVersion
+	split    *TrafficSplit
+}
+
+// hasConstraints tells whether the route options require the route
+// to be registered as a variant.
+func (opts *routeOptions[T]) hasConstraints() bool {
+	return len(opts.produces) > 0 || len(opts.consumes) > 0 ||
+		len(opts.matchers) > 0 || opts.version != "" || opts.split != nil
+}
+
+func newVariant[T HandlerConstraint](route *Route[T], handler T, split *TrafficSplit) *variant[T] {
+	v := &variant[T]{
+		route:    route,
+		handler:  handler,
+		produces: parseMediaRanges(route.Produces),
+		consumes: parseMediaRanges(route.Consumes),
+		split:    split,
+	}
+	if route.Version != "" {
+		version, ok := parseVersion(route.Version)
+		if !ok {
+			panic(fmt.Sprintf("treemux: invalid API version %q", route.Version))
+		}
+		v.version = version
+	}
+	return v
+}
+
+// variantSet holds the variants registered for a method of a node.
+type variantSet[T HandlerConstraint] struct {
+	variants []*variant[T]
+
+	// hasDefault tells that a handler without constraints is registered,
+	// which serves the requests that no variant matches.
+	hasDefault bool
+
+	// hasVersions tells that some variants have API versions.
+	hasVersions bool
+
+	// hasSplits tells that some variants belong to traffic splits.
+	hasSplits bool
+
+	// vary is the request headers which select variants by content
+	// negotiation, i.e. `Content-Type` and `Accept`.
+	vary []string
+}
+
+// addVariant adds a variant to the node.
+func (n *node[T]) addVariant(method string, v *variant[T]) {
+	if n.leafVariants == nil {
+		n.leafVariants = make(map[string]*variantSet[T])
+	}
+	set := n.leafVariants[method]
+	if set == nil {
+		set = &variantSet[T]{}
+		n.leafVariants[method] = set
+		if _, ok := n.leafHandlers[method]; ok {
+			set.hasDefault = true
+		} else {
+			// Set the first variant as the node's handler, thus the node
+			// is considered to have a valid handler when searching the tree.
+			n.setHandler(method, v.handler, false)
+			n.setRoute(method, v.route)
+		}
+	}
+
+	// Keep the variants ordered by the number of matchers descending,
+	// variants with the same number of matchers are kept in the
+	// registering order.
+	i := len(set.variants)
+	for i > 0 && len(set.variants[i-1].route.Matchers) < len(v.route.Matchers) {
+		i--
+	}
+	set.variants = append(set.variants, nil)
+	copy(set.variants[i+1:], set.variants[i:])
+	set.variants[i] = v
+	if v.version != nil {
+		set.hasVersions = true
+	}
+	if v.split != nil {
+		set.hasSplits = true
+	}
+	if len(v.consumes) > 0 && !containsString(set.vary, "Content-Type") {
+		set.vary = append(set.vary, "Content-Type")
+	}
+	if len(v.produces) > 0 && !containsString(set.vary, "Accept") {
+		set.vary = append(set.vary, "Accept")
+	}
+}
+
+// setDefaultHandler sets a handler without constraints for method.
+// It reports false if no variant is registered for method.
+func (n *node[T]) setDefaultHandler(method string, handler T, route *Route[T]) bool {
+	set := n.leafVariants[method]
+	if set == nil {
+		return false
+	}
+	if set.hasDefault {
+		panic(fmt.Sprintf("treemux: %s already handles %s", n.path, method))
+	}
+	set.hasDefault = true
+	n.leafHandlers[method] = handler
+	n.setRoute(method, route)
+	return true
+}
+
+// variantKey returns the method key whose variants are used to serve
+// a request of method.
+func (n *node[T]) variantKey(method string) string {
+	if method == "HEAD" && n.implicitHead {
+		return "GET"
+	}
+	if _, ok := n.leafHandlers[method]; ok {
+		return method
+	}
+	return MethodAny
+}
+
+// variantResult is the result of selecting a variant for a request.
+type variantResult[T HandlerConstraint] struct {
+	handler   T
+	route     *Route[T]
+	mediaType string
+	vary      []string
+
+	// statusCode is non-zero if no variant matches the request.
+	statusCode int
+
+	// allowedMethods is set when statusCode is http.StatusMethodNotAllowed.
+	allowedMethods []string
+}
+
+// selectVariant selects the handler to serve the request from the
+// variants registered to the node.
+// If r is nil, the request is treated as an empty request.
+func (n *node[T]) selectVariant(method string, r *http.Request, extractVersion VersionExtractor) (result variantResult[T], ok bool) {
+	set := n.leafVariants[n.variantKey(method)]
+	if set == nil {
+		return result, false
+	}
+
+	if r == nil {
+		r = emptyRequest
+	}
+	result.vary = set.vary
+
+	candidates := filterByMatchers(set.variants, r)
+	if len(candidates) == 0 {
+		result.allowedMethods = n.matchedMethods(r)
+		if len(result.allowedMethods) > 0 {
+			result.statusCode = http.StatusMethodNotAllowed
+		} else {
+			result.statusCode = http.StatusNotFound
+		}
+	} else if set.hasVersions && extractVersion != nil {
+		candidates, result.statusCode = filterByVersion(candidates, extractVersion(r))
+	}
+	if result.statusCode == 0 && set.hasSplits {
+		candidates = filterBySplit(candidates, r)
+	}
+	if result.statusCode == 0 {
+		candidates, result.statusCode = filterByContentType(candidates, r.Header.Get("Content-Type"))
+	}
+	if result.statusCode == 0 {
+		var v *variant[T]
+		v, result.mediaType, result.statusCode = negotiateAccept(candidates, r.Header.Get("Accept"))
+		if v != nil {
+			result.handler = v.handler
+			result.route = v.route
+			return result, true
+		}
+	}
+
+	if set.hasDefault {
+		key := n.variantKey(method)
+		result = variantResult[T]{
+			handler: n.leafHandlers[key],
+			route:   n.leafRoutes[key],
+			vary:    set.vary,
+		}
+	}
+	return result, true
+}
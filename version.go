@@ -0,0 +1,205 @@
+package treemux
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// VersionExtractor extracts the requested API version from a request.
+// It returns an empty string if the request does not specify a version.
+type VersionExtractor func(r *http.Request) string
+
+// DefaultVersionExtractor extracts the API version from the
+// `X-API-Version` header, then from the `Accept` header by
+// VersionFromMediaType.
+var DefaultVersionExtractor = ChainVersionExtractors(
+	VersionFromHeader("X-API-Version"),
+	VersionFromMediaType(),
+)
+
+// VersionFromHeader returns a VersionExtractor which extracts the
+// version from the request header key.
+func VersionFromHeader(key string) VersionExtractor {
+	return func(r *http.Request) string {
+		return strings.TrimSpace(r.Header.Get(key))
+	}
+}
+
+// VersionFromQuery returns a VersionExtractor which extracts the
+// version from the query parameter key.
+func VersionFromQuery(key string) VersionExtractor {
+	return func(r *http.Request) string {
+		if r.URL == nil || r.URL.RawQuery == "" {
+			return ""
+		}
+		query, err := url.ParseQuery(r.URL.RawQuery)
+		if err != nil {
+			return ""
+		}
+		return query.Get(key)
+	}
+}
+
+var vendorVersionRE = regexp.MustCompile(`\.v(\d+(?:\.\d+)*)(?:\+|$)`)
+
+// VersionFromMediaType returns a VersionExtractor which extracts the
+// version from the `Accept` header. Both a vendor media type like
+// "application/vnd.acme.v2+json" and a media type parameter like
+// "application/json; version=2" are recognized.
+func VersionFromMediaType() VersionExtractor {
+	return func(r *http.Request) string {
+		accept := r.Header.Get("Accept")
+		if accept == "" {
+			return ""
+		}
+		for _, part := range strings.Split(accept, ",") {
+			params := strings.Split(part, ";")
+			for _, param := range params[1:] {
+				key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+				if strings.EqualFold(strings.TrimSpace(key), "version") {
+					return strings.Trim(strings.TrimSpace(value), `"`)
+				}
+			}
+			if m := vendorVersionRE.FindStringSubmatch(strings.TrimSpace(params[0])); m != nil {
+				return m[1]
+			}
+		}
+		return ""
+	}
+}
+
+// ChainVersionExtractors returns a VersionExtractor which calls the
+// extractors in order and returns the first non-empty version.
+func ChainVersionExtractors(extractors ...VersionExtractor) VersionExtractor {
+	return func(r *http.Request) string {
+		for _, extract := range extractors {
+			if v := extract(r); v != "" {
+				return v
+			}
+		}
+		return ""
+	}
+}
+
+// Version returns a derived Group which has the same path prefix and
+// middlewares as g, routes added to the returned Group are registered
+// with the API version.
+//
+// Handlers of several versions can be registered for the same method
+// and pattern. The router extracts the requested version using
+// Router.VersionExtractor, and serves the request with the latest
+// compatible version, that is the highest registered version which has
+// the same major version as, and is not higher than the requested
+// version. If the request does not specify a version, the latest
+// version is used. The served version is reported by
+// LookupResult.Version and RouteData.Version.
+func (g *Group[T]) Version(version string) *Group[T] {
+	ng := g.derive(nil)
+	ng.version = version
+	ng.versionDeprecated = false
+	return ng
+}
+
+// DeprecatedVersion is like Version, but marks the version as deprecated.
+// Responses served by a deprecated version have the header
+// `Deprecation: true`.
+func (g *Group[T]) DeprecatedVersion(version string) *Group[T] {
+	ng := g.derive(nil)
+	ng.version = version
+	ng.versionDeprecated = true
+	return ng
+}
+
+// WithVersion returns a RouteOption which registers the route with the
+// API version, see Group.Version for details.
+func WithVersion[T HandlerConstraint](version string, deprecated bool) RouteOption[T] {
+	return func(opts *routeOptions[T]) {
+		opts.version = version
+		opts.versionDeprecated = deprecated
+	}
+}
+
+// apiVersion is a parsed API version, e.g. "v2.1" is parsed as [2 1].
+type apiVersion []int
+
+func parseVersion(s string) (apiVersion, bool) {
+	s = strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(s), "v"), "V")
+	if s == "" {
+		return nil, false
+	}
+	parts := strings.Split(s, ".")
+	out := make(apiVersion, 0, len(parts))
+	for _, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return nil, false
+		}
+		out = append(out, n)
+	}
+	return out, true
+}
+
+func (v apiVersion) compare(other apiVersion) int {
+	for i := 0; i < len(v) || i < len(other); i++ {
+		var a, b int
+		if i < len(v) {
+			a = v[i]
+		}
+		if i < len(other) {
+			b = other[i]
+		}
+		if a != b {
+			if a < b {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// filterByVersion returns the variants of the version which best
+// matches the requested version. Variants without a version are
+// returned if no versioned variant is compatible with the requested
+// version, they are the fallback of the route.
+// If there is no such fallback, it returns status code
+// http.StatusNotFound.
+func filterByVersion[T HandlerConstraint](variants []*variant[T], requested string) ([]*variant[T], int) {
+	var target *variant[T]
+	reqVersion, reqOK := parseVersion(requested)
+	for _, v := range variants {
+		if v.version == nil {
+			continue
+		}
+		if requested != "" {
+			if !reqOK || v.version[0] != reqVersion[0] || v.version.compare(reqVersion) > 0 {
+				continue
+			}
+		}
+		if target == nil || v.version.compare(target.version) > 0 {
+			target = v
+		}
+	}
+
+	hasVersion := false
+	var out []*variant[T]
+	for _, v := range variants {
+		if v.version != nil {
+			hasVersion = true
+		}
+		if (target == nil && v.version == nil) ||
+			(target != nil && v.version != nil && v.version.compare(target.version) == 0) {
+			out = append(out, v)
+		}
+	}
+	if !hasVersion {
+		return variants, 0
+	}
+	if len(out) == 0 {
+		return nil, http.StatusNotFound
+	}
+	return out, 0
+}
@@ -0,0 +1,125 @@
+package treemux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestVersionRouting(t *testing.T) {
+	var result string
+	makeHandler := func(name string) HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request, params Params) {
+			result = name
+		}
+	}
+
+	router := New[HandlerFunc]()
+	router.UseContextData = true
+	router.DeprecatedVersion("1").GET("/users", makeHandler("v1"))
+	router.Version("2").GET("/users", makeHandler("v2"))
+	router.Version("2.1").GET("/users", makeHandler("v2.1"))
+	router.GET("/users", makeHandler("v3"), WithVersion[HandlerFunc]("v3", false))
+
+	tests := []struct {
+		header         map[string]string
+		wantCode       int
+		wantResult     string
+		wantDeprecated bool
+	}{
+		{nil, 200, "v3", false},
+		{map[string]string{"X-API-Version": "1"}, 200, "v1", true},
+		{map[string]string{"X-API-Version": "2"}, 200, "v2", false},
+		{map[string]string{"X-API-Version": "2.5"}, 200, "v2.1", false},
+		{map[string]string{"X-API-Version": "v3"}, 200, "v3", false},
+		{map[string]string{"X-API-Version": "4"}, 404, "", false},
+		{map[string]string{"X-API-Version": "bad"}, 404, "", false},
+		{map[string]string{"Accept": "application/vnd.acme.v2+json"}, 200, "v2", false},
+		{map[string]string{"Accept": "application/json; version=1"}, 200, "v1", true},
+	}
+	for _, tt := range tests {
+		result = ""
+		w := httptest.NewRecorder()
+		r, _ := newRequest("GET", "/users", nil)
+		for k, v := range tt.header {
+			r.Header.Set(k, v)
+		}
+		router.ServeHTTP(w, r)
+		if w.Code != tt.wantCode || result != tt.wantResult {
+			t.Errorf("%v: got code %d result %q, want %d %q",
+				tt.header, w.Code, result, tt.wantCode, tt.wantResult)
+		}
+		if got := w.Header().Get("Deprecation") == "true"; got != tt.wantDeprecated {
+			t.Errorf("%v: got deprecated %v, want %v", tt.header, got, tt.wantDeprecated)
+		}
+	}
+
+	r, _ := newRequest("GET", "/users", nil)
+	r.Header.Set("X-API-Version", "2.1")
+	lr, found := router.Lookup(httptest.NewRecorder(), r)
+	if !found || lr.Version != "2.1" || lr.Route().Version != "2.1" {
+		t.Errorf("lookup got version %q, want 2.1", lr.Version)
+	}
+}
+
+func TestVersionUnversionedFallback(t *testing.T) {
+	var result string
+	makeHandler := func(name string) HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request, params Params) {
+			result = name
+		}
+	}
+
+	router := New[HandlerFunc]()
+	router.GET("/items", makeHandler("unversioned"))
+	router.Version("2").GET("/items", makeHandler("v2"))
+
+	tests := []struct {
+		version    string
+		wantResult string
+	}{
+		{"", "v2"},
+		{"2", "v2"},
+		{"1", "unversioned"},
+		{"3", "unversioned"},
+		{"bad", "unversioned"},
+	}
+	for _, tt := range tests {
+		result = ""
+		w := httptest.NewRecorder()
+		r, _ := newRequest("GET", "/items", nil)
+		if tt.version != "" {
+			r.Header.Set("X-API-Version", tt.version)
+		}
+		router.ServeHTTP(w, r)
+		if w.Code != http.StatusOK || result != tt.wantResult {
+			t.Errorf("version %q: got code %d result %q, want %q",
+				tt.version, w.Code, result, tt.wantResult)
+		}
+	}
+}
+
+func TestVersionExtractors(t *testing.T) {
+	router := New[HandlerFunc]()
+	router.VersionExtractor = VersionFromQuery("api-version")
+	var result string
+	router.Version("1").GET("/ping", func(w http.ResponseWriter, r *http.Request, params Params) {
+		result = "v1"
+	})
+	router.GET("/ping", func(w http.ResponseWriter, r *http.Request, params Params) {
+		result = "default"
+	})
+
+	for path, want := range map[string]string{
+		"/ping?api-version=1": "v1",
+		"/ping?api-version=2": "default",
+		"/ping":               "v1",
+	} {
+		result = ""
+		r, _ := newRequest("GET", path, nil)
+		router.ServeHTTP(httptest.NewRecorder(), r)
+		if result != want {
+			t.Errorf("%s: got %q, want %q", path, result, want)
+		}
+	}
+}